@@ -0,0 +1,139 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+var _ conversion.Convertible = &AuthProvider{}
+
+// Names the controller resolved AuthProviders by before spec.type existed
+const (
+	legacyGenAIAuthProviderName  = "genai-auth-provider"
+	legacyArgoCDAuthProviderName = "argocd-auth-provider"
+)
+
+// ConvertTo converts this AuthProvider to the hub version. The identity fields kept in
+// spec.auth for older AuthProviders move to spec.genai when it is not set, and AuthProviders
+// without spec.type get the type worked out by legacyType. The fields v1alpha1 has no place for
+// are restored from the hub spec annotation.
+func (src *AuthProvider) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha2.AuthProvider)
+	in := src.DeepCopy()
+
+	stored := &v1alpha2.AuthProviderSpec{}
+	if data, ok := in.Annotations[HubSpecAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), stored); err != nil {
+			return err
+		}
+		delete(in.Annotations, HubSpecAnnotation)
+	}
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = v1alpha2.AuthProviderSpec{
+		Type:              v1alpha2.AuthProviderType(legacyType(in)),
+		SecretRef:         in.Spec.SecretRef,
+		Plugin:            stored.Plugin,
+		OpenAI:            stored.OpenAI,
+		Anthropic:         stored.Anthropic,
+		Ollama:            stored.Ollama,
+		TLS:               stored.TLS,
+		ProxyURL:          stored.ProxyURL,
+		AllowedNamespaces: stored.AllowedNamespaces,
+	}
+	dst.Spec.Auth = v1alpha2.Auth{
+		Scheme:          stored.Auth.Scheme,
+		Bearer:          stored.Auth.Bearer,
+		Basic:           stored.Auth.Basic,
+		APIKey:          stored.Auth.APIKey,
+		OAuth2:          stored.Auth.OAuth2,
+		IdentityService: stored.Auth.IdentityService,
+	}
+	if auth := in.Spec.Auth; auth != nil {
+		dst.Spec.Auth.BaseURL = auth.BaseURL
+		if auth.AppID != "" || auth.IdentityEndpoint != "" || auth.IdentityJobID != "" || auth.APIVersion != "" {
+			dst.Spec.GenAI = &v1alpha2.GenAISettings{
+				AppID:            auth.AppID,
+				IdentityEndpoint: auth.IdentityEndpoint,
+				IdentityJobID:    auth.IdentityJobID,
+				APIVersion:       auth.APIVersion,
+			}
+		}
+	}
+	if in.Spec.GenAI != nil {
+		genAI := v1alpha2.GenAISettings(*in.Spec.GenAI)
+		dst.Spec.GenAI = &genAI
+	}
+	if in.Spec.ArgoCD != nil {
+		argoCD := v1alpha2.ArgoCDSettings(*in.Spec.ArgoCD)
+		dst.Spec.ArgoCD = &argoCD
+	}
+	if in.Spec.Webhook != nil {
+		webhook := v1alpha2.WebhookSettings(*in.Spec.Webhook)
+		dst.Spec.Webhook = &webhook
+	}
+
+	dst.Status = v1alpha2.AuthProviderStatus(in.Status)
+	return nil
+}
+
+// legacyType returns the type of the AuthProvider, working it out for AuthProviders created before
+// spec.type existed. Those were resolved by the names genai-auth-provider and argocd-auth-provider,
+// and the ones with identity settings were probed as GenAI and all others as Argo CD servers.
+func legacyType(in *AuthProvider) AuthProviderType {
+	spec := in.Spec
+	switch {
+	case spec.Type != "":
+		return spec.Type
+	case spec.GenAI != nil:
+		return AuthProviderTypeGenAI
+	case spec.ArgoCD != nil:
+		return AuthProviderTypeArgoCD
+	case spec.Webhook != nil:
+		return AuthProviderTypeWebhook
+	case in.Name == legacyGenAIAuthProviderName:
+		return AuthProviderTypeGenAI
+	case in.Name == legacyArgoCDAuthProviderName:
+		return AuthProviderTypeArgoCD
+	case spec.Auth != nil && (spec.Auth.IdentityEndpoint != "" || spec.Auth.AppID != "" || spec.Auth.IdentityJobID != ""):
+		return AuthProviderTypeGenAI
+	default:
+		return AuthProviderTypeArgoCD
+	}
+}
+
+// ConvertFrom converts from the hub version to this version
+func (dst *AuthProvider) ConvertFrom(srcRaw conversion.Hub) error {
+	in := srcRaw.(*v1alpha2.AuthProvider).DeepCopy()
+
+	data, err := json.Marshal(in.Spec)
+	if err != nil {
+		return err
+	}
+	dst.ObjectMeta = in.ObjectMeta
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[HubSpecAnnotation] = string(data)
+	dst.Spec = AuthProviderSpec{
+		Type:      AuthProviderType(in.Spec.Type),
+		SecretRef: in.Spec.SecretRef,
+		Auth:      &Auth{BaseURL: in.Spec.Auth.BaseURL},
+	}
+	if in.Spec.GenAI != nil {
+		genAI := GenAISettings(*in.Spec.GenAI)
+		dst.Spec.GenAI = &genAI
+	}
+	if in.Spec.ArgoCD != nil {
+		argoCD := ArgoCDSettings(*in.Spec.ArgoCD)
+		dst.Spec.ArgoCD = &argoCD
+	}
+	if in.Spec.Webhook != nil {
+		webhook := WebhookSettings(*in.Spec.Webhook)
+		dst.Spec.Webhook = &webhook
+	}
+
+	dst.Status = AuthProviderStatus(in.Status)
+	return nil
+}
@@ -29,19 +29,46 @@ type AuthProviderSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// Type selects the backend the AuthProvider connects to and the client built for it. It is
+	// optional in this version so that AuthProviders created before it existed stay valid, the
+	// conversion to v1alpha2 works it out for them.
+	// +kubebuilder:validation:Optional
+	Type AuthProviderType `json:"type,omitempty"`
 	// SecretRef contains the credentials required to auth to a specific wf_executor
 	SecretRef *v1.LocalObjectReference `json:"secretRef,omitempty"`
 	Auth      *Auth                    `json:"auth,omitempty"`
+	// GenAI holds the settings used when type is genai
+	// +kubebuilder:validation:Optional
+	GenAI *GenAISettings `json:"genai,omitempty"`
+	// ArgoCD holds the settings used when type is argocd
+	// +kubebuilder:validation:Optional
+	ArgoCD *ArgoCDSettings `json:"argocd,omitempty"`
+	// Webhook holds the settings used when type is webhook
+	// +kubebuilder:validation:Optional
+	Webhook *WebhookSettings `json:"webhook,omitempty"`
 }
 
 // AuthProviderStatus defines the observed state of AuthProvider
 type AuthProviderStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Conditions reports the outcome of the latest secret and endpoint checks
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastCheckedTime is the time the secret and endpoint were last verified
+	LastCheckedTime *metav1.Time `json:"lastCheckedTime,omitempty"`
+	// The generation observed by the controller from metadata.generation
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="Last Checked",type="date",JSONPath=".status.lastCheckedTime"
 
 // AuthProvider is the Schema for the authproviders API
 type AuthProvider struct {
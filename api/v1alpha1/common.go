@@ -27,12 +27,144 @@ type ArgoSupportPhase string
 
 // Possible ArgoSupportPhase values
 const (
+	ArgoSupportPhasePending   ArgoSupportPhase = "pending"
 	ArgoSupportPhaseRunning   ArgoSupportPhase = "running"
 	ArgoSupportPhaseCompleted ArgoSupportPhase = "completed"
 	ArgoSupportPhaseFailed    ArgoSupportPhase = "failed"
 	ArgoSupportPhaseError     ArgoSupportPhase = "error"
+	ArgoSupportPhaseCancelled ArgoSupportPhase = "cancelled"
 )
 
+// AuthProvider condition types
+const (
+	// AuthProviderConditionReady is true when the secret is present and the endpoint answered the probe
+	AuthProviderConditionReady = "Ready"
+	// AuthProviderConditionSecretMissing is true when the referenced Secret or its app.secret key is missing
+	AuthProviderConditionSecretMissing = "SecretMissing"
+	// AuthProviderConditionUnreachable is true when the endpoint could not be probed successfully
+	AuthProviderConditionUnreachable = "Unreachable"
+)
+
+// AuthProvider condition reasons
+const (
+	AuthProviderReasonVerified            = "Verified"
+	AuthProviderReasonSecretRefMissing    = "SecretRefMissing"
+	AuthProviderReasonSecretNotFound      = "SecretNotFound"
+	AuthProviderReasonSecretKeyNotFound   = "SecretKeyNotFound"
+	AuthProviderReasonSecretFound         = "SecretFound"
+	AuthProviderReasonInvalidEndpoint     = "InvalidEndpoint"
+	AuthProviderReasonEndpointUnreachable = "EndpointUnreachable"
+	AuthProviderReasonEndpointReachable   = "EndpointReachable"
+	AuthProviderReasonNotVerified         = "NotVerified"
+)
+
+// Support condition types
+const (
+	// SupportConditionContextCollected is true when the context of the target was collected
+	SupportConditionContextCollected = "ContextCollected"
+	// SupportConditionProviderReachable is true when the AuthProviders resolved and the LLM provider answered
+	SupportConditionProviderReachable = "ProviderReachable"
+	// SupportConditionAnalysisSucceeded is true when the latest analysis produced a result
+	SupportConditionAnalysisSucceeded = "AnalysisSucceeded"
+	// SupportConditionStale is true when the spec changed after the latest result was produced
+	SupportConditionStale = "Stale"
+)
+
+// Support condition reasons
+const (
+	SupportReasonCollected            = "Collected"
+	SupportReasonCollectionFailed     = "CollectionFailed"
+	SupportReasonReachable            = "Reachable"
+	SupportReasonAuthProviderNotFound = "AuthProviderNotFound"
+	SupportReasonProviderUnavailable  = "ProviderUnavailable"
+	SupportReasonRequestFailed        = "RequestFailed"
+	SupportReasonSucceeded            = "Succeeded"
+	SupportReasonInvalidResponse      = "InvalidResponse"
+	SupportReasonConfigurationError   = "ConfigurationError"
+	SupportReasonUnknownWorkflow      = "UnknownWorkflow"
+	SupportReasonRetryLimitReached    = "RetryLimitReached"
+	SupportReasonPermanentFailure     = "PermanentFailure"
+	SupportReasonCancelled            = "Cancelled"
+	SupportReasonSpecChanged          = "SpecChanged"
+	SupportReasonUpToDate             = "UpToDate"
+	SupportReasonAuthProviderChanged  = "AuthProviderChanged"
+)
+
+// AuthProviderType identifies the backend an AuthProvider connects to
+// +kubebuilder:validation:Enum=genai;argocd;webhook;plugin;openai;anthropic;ollama
+type AuthProviderType string
+
+// Possible AuthProviderType values
+const (
+	// AuthProviderTypeGenAI is the GenAI analyze service authenticated through the identity service
+	AuthProviderTypeGenAI AuthProviderType = "genai"
+	// AuthProviderTypeArgoCD is an Argo CD API server
+	AuthProviderTypeArgoCD AuthProviderType = "argocd"
+	// AuthProviderTypeWebhook is a self-hosted service speaking the GenAI analyze protocol
+	AuthProviderTypeWebhook AuthProviderType = "webhook"
+	// AuthProviderTypePlugin is an external service running workflows through the executor plugin protocol
+	AuthProviderTypePlugin AuthProviderType = "plugin"
+	// AuthProviderTypeOpenAI is a server speaking the OpenAI chat completions API, such as OpenAI, vLLM or LocalAI
+	AuthProviderTypeOpenAI AuthProviderType = "openai"
+	// AuthProviderTypeAnthropic is the Anthropic Messages API
+	AuthProviderTypeAnthropic AuthProviderType = "anthropic"
+	// AuthProviderTypeOllama is a self-hosted server speaking the Ollama chat API, which keeps the
+	// collected context inside the cluster
+	AuthProviderTypeOllama AuthProviderType = "ollama"
+)
+
+// ProviderRole is the part an AuthProvider plays in a workflow
+type ProviderRole string
+
+// Possible ProviderRole values
+const (
+	// ProviderRoleLLM analyzes the collected context
+	ProviderRoleLLM ProviderRole = "llm"
+	// ProviderRoleGitOps is the source of the application state
+	ProviderRoleGitOps ProviderRole = "gitops"
+	// ProviderRoleExecutor runs the workflow out of process
+	ProviderRoleExecutor ProviderRole = "executor"
+)
+
+// Role returns the role an AuthProvider of this type fills in a workflow
+func (t AuthProviderType) Role() ProviderRole {
+	switch t {
+	case AuthProviderTypeArgoCD:
+		return ProviderRoleGitOps
+	case AuthProviderTypeGenAI, AuthProviderTypeWebhook, AuthProviderTypeOpenAI, AuthProviderTypeAnthropic, AuthProviderTypeOllama:
+		return ProviderRoleLLM
+	case AuthProviderTypePlugin:
+		return ProviderRoleExecutor
+	default:
+		return ""
+	}
+}
+
+// GenAISettings configures the identity service flow of a genai AuthProvider
+type GenAISettings struct {
+	AppID            string `json:"appId,omitempty"`
+	IdentityEndpoint string `json:"identityEndpoint,omitempty"`
+	IdentityJobID    string `json:"identityJobID,omitempty"`
+	APIVersion       string `json:"apiVersion,omitempty"`
+}
+
+// ArgoCDSettings configures an argocd AuthProvider
+type ArgoCDSettings struct {
+	// AppNamespace is the namespace of the Argo CD Applications when apps in any namespace is enabled
+	AppNamespace string `json:"appNamespace,omitempty"`
+}
+
+// WebhookSettings configures a webhook AuthProvider
+type WebhookSettings struct {
+	// APIVersion is the path segment placed between the base URL and the analyze endpoint
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Headers are added to every request sent to the webhook
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Auth holds the connection settings shared by all AuthProvider types.
+// AppID, IdentityEndpoint, IdentityJobID and APIVersion are kept for AuthProviders created
+// before spec.genai existed and are only read when spec.genai is not set.
 type Auth struct {
 	BaseURL          string `json:"baseUrl,omitempty"`
 	AppID            string `json:"appId,omitempty"`
@@ -52,7 +184,8 @@ type Workflow struct {
 	// +kubebuilder:validation:Optional
 	ConfigMapRef ConfigMapRef `json:"configMapRef"`
 	RetryLimit   int64        `json:"retryLimit,omitempty"`
-	Delay        int          `json:"delay,omitempty"`
+	// Delay between attempts in seconds
+	Delay int `json:"delay,omitempty"`
 }
 
 type NamespacedObjectReference struct {
@@ -0,0 +1,205 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSupportConversion(t *testing.T) {
+	src := &Support{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "support"},
+		Spec: SupportSpec{Workflows: []Workflow{{
+			Name:         "gen-ai",
+			Ref:          []NamespacedObjectReference{{Name: "genai-auth-provider"}},
+			ConfigMapRef: ConfigMapRef{Name: "genai-cm"},
+			RetryLimit:   3,
+			Delay:        30,
+		}}},
+		Status: SupportStatus{Results: []Result{{
+			Name:    "gen-ai-1",
+			Summary: Summary{MainSummary: "pods are crash looping"},
+			Analysis: &Analysis{
+				RootCause:         "the readiness probe points at the wrong port",
+				Category:          FailureCategoryHealthCheck,
+				AffectedResources: []ObjectReference{{Group: "argoproj.io", Kind: "Rollout", Name: "guestbook"}},
+				Confidence:        ConfidenceHigh,
+			},
+		}, {
+			Name:    "gen-ai-2",
+			Phase:   ArgoSupportPhaseCancelled,
+			Message: "rollout was aborted",
+		}}, Workflows: []WorkflowStatus{{
+			Name:       "gen-ai",
+			Phase:      ArgoSupportPhaseCompleted,
+			Attempts:   1,
+			ResultRefs: []string{"gen-ai-1"},
+		}}, Count: 1},
+	}
+
+	hub := &v1alpha2.Support{}
+	if err := src.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	wf := hub.Spec.Workflows[0]
+	if wf.AuthProviderRefs[0].Name != "genai-auth-provider" || wf.ConfigMapRef.Name != "genai-cm" || wf.Delay.Duration != 30*time.Second {
+		t.Errorf("unexpected hub workflow %+v", wf)
+	}
+	if hub.Status.Results[0].Summary != "pods are crash looping" {
+		t.Errorf("expected the summary to be kept, got %q", hub.Status.Results[0].Summary)
+	}
+	if hub.Status.Results[1].Phase != v1alpha2.ArgoSupportPhaseCancelled {
+		t.Errorf("expected the cancelled result to keep its phase, got %q", hub.Status.Results[1].Phase)
+	}
+
+	dst := &Support{}
+	if err := dst.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	delete(dst.Annotations, HubSpecAnnotation)
+	if !equality.Semantic.DeepEqual(src.Spec, dst.Spec) || !equality.Semantic.DeepEqual(src.Status, dst.Status) {
+		t.Errorf("round trip changed the Support:\n%+v\n%+v", src, dst)
+	}
+}
+
+func TestSupportConversionKeepsHubOnlyFields(t *testing.T) {
+	limit := int32(5)
+	hub := &v1alpha2.Support{
+		Spec: v1alpha2.SupportSpec{
+			Workflows: []v1alpha2.Workflow{{
+				Name:  "gen-ai",
+				Delay: &metav1.Duration{Duration: 1500 * time.Millisecond},
+			}, {
+				Name:      "notify",
+				DependsOn: []string{"gen-ai"},
+			}},
+			ResultHistoryLimit: &limit,
+			Suspend:            true,
+		},
+	}
+	spoke := &Support{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if spoke.Spec.Workflows[0].Delay != 1 {
+		t.Errorf("expected the delay in whole seconds, got %d", spoke.Spec.Workflows[0].Delay)
+	}
+
+	restored := &v1alpha2.Support{}
+	if err := spoke.ConvertTo(restored); err != nil {
+		t.Fatal(err)
+	}
+	if d := restored.Spec.Workflows[0].Delay.Duration; d != 1500*time.Millisecond {
+		t.Errorf("expected the delay to survive the round trip, got %s", d)
+	}
+	if restored.Spec.ResultHistoryLimit == nil || *restored.Spec.ResultHistoryLimit != 5 {
+		t.Errorf("expected the result history limit to survive the round trip, got %v", restored.Spec.ResultHistoryLimit)
+	}
+	if deps := restored.Spec.Workflows[1].DependsOn; len(deps) != 1 || deps[0] != "gen-ai" {
+		t.Errorf("expected dependsOn to survive the round trip, got %v", deps)
+	}
+	if !restored.Spec.Suspend {
+		t.Error("expected suspend to survive the round trip")
+	}
+	if _, ok := restored.Annotations[HubSpecAnnotation]; ok {
+		t.Error("expected the hub spec annotation to be removed")
+	}
+}
+
+func TestAuthProviderConversionWithoutType(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     AuthProviderSpec
+		expected v1alpha2.AuthProviderType
+	}{
+		{"genai-auth-provider", AuthProviderSpec{Auth: &Auth{BaseURL: "https://genai.example.com"}}, v1alpha2.AuthProviderTypeGenAI},
+		{"argocd-auth-provider", AuthProviderSpec{Auth: &Auth{BaseURL: "https://argocd.example.com"}}, v1alpha2.AuthProviderTypeArgoCD},
+		{"analyzer", AuthProviderSpec{Auth: &Auth{BaseURL: "https://genai.example.com", IdentityEndpoint: "https://identity.example.com"}}, v1alpha2.AuthProviderTypeGenAI},
+		{"gitops", AuthProviderSpec{Auth: &Auth{BaseURL: "https://argocd.example.com"}}, v1alpha2.AuthProviderTypeArgoCD},
+		{"typed", AuthProviderSpec{Type: AuthProviderTypeWebhook, Auth: &Auth{BaseURL: "https://analyzer.example.com"}}, v1alpha2.AuthProviderTypeWebhook},
+	} {
+		src := &AuthProvider{ObjectMeta: metav1.ObjectMeta{Name: tc.name}, Spec: tc.spec}
+		hub := &v1alpha2.AuthProvider{}
+		if err := src.ConvertTo(hub); err != nil {
+			t.Fatal(err)
+		}
+		if hub.Spec.Type != tc.expected {
+			t.Errorf("expected %s to convert to type %s, got %q", tc.name, tc.expected, hub.Spec.Type)
+		}
+	}
+}
+
+func TestAuthProviderConversion(t *testing.T) {
+	src := &AuthProvider{
+		Spec: AuthProviderSpec{
+			Type: AuthProviderTypeGenAI,
+			Auth: &Auth{BaseURL: "https://genai.example.com", AppID: "app", IdentityEndpoint: "https://identity.example.com"},
+		},
+	}
+	hub := &v1alpha2.AuthProvider{}
+	if err := src.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.Auth.BaseURL != "https://genai.example.com" || hub.Spec.GenAI == nil || hub.Spec.GenAI.AppID != "app" {
+		t.Errorf("expected the legacy identity fields to move to spec.genai, got %+v", hub.Spec)
+	}
+
+	for _, hub := range []*v1alpha2.AuthProvider{{Spec: v1alpha2.AuthProviderSpec{
+		Type:   v1alpha2.AuthProviderTypePlugin,
+		Auth:   v1alpha2.Auth{BaseURL: "http://plugin.example.com"},
+		Plugin: &v1alpha2.PluginSettings{Workflows: []string{"in-house"}},
+	}}, {Spec: v1alpha2.AuthProviderSpec{
+		Type:   v1alpha2.AuthProviderTypeOpenAI,
+		Auth:   v1alpha2.Auth{BaseURL: "https://api.openai.com/v1"},
+		OpenAI: &v1alpha2.LLMSettings{Model: "gpt-4o-mini", Temperature: "0.2", MaxTokens: 1024},
+	}}, {Spec: v1alpha2.AuthProviderSpec{
+		Type:      v1alpha2.AuthProviderTypeAnthropic,
+		Auth:      v1alpha2.Auth{BaseURL: "https://api.anthropic.com"},
+		Anthropic: &v1alpha2.LLMSettings{Model: "claude-sonnet-4-5", MaxTokens: 2048},
+	}}, {Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeOllama,
+		Auth: v1alpha2.Auth{BaseURL: "http://ollama.llm.svc:11434"},
+		Ollama: &v1alpha2.OllamaSettings{
+			LLMSettings: v1alpha2.LLMSettings{Model: "llama3.1:8b", Timeout: &metav1.Duration{Duration: 30 * time.Minute}},
+			PullModel:   true,
+		},
+	}}, {Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeWebhook,
+		Auth: v1alpha2.Auth{
+			BaseURL: "https://analyzer.example.com",
+			Scheme:  v1alpha2.AuthSchemeOAuth2,
+			OAuth2:  &v1alpha2.OAuth2Auth{TokenURL: "https://login.example.com/token", Scopes: []string{"analyze"}, ClientIDKey: "id"},
+		},
+	}}, {Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeArgoCD,
+		Auth: v1alpha2.Auth{BaseURL: "https://argocd.example.com"},
+		TLS: &v1alpha2.TLSSettings{
+			CABundle: &v1alpha2.CABundleSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "internal-ca"},
+				Key:                  "ca.crt",
+			}},
+			ClientCertificate: &corev1.LocalObjectReference{Name: "argocd-client"},
+		},
+		ProxyURL:          "http://egress.example.com:3128",
+		AllowedNamespaces: []string{"team-a"},
+	}}} {
+		spoke := &AuthProvider{}
+		if err := spoke.ConvertFrom(hub); err != nil {
+			t.Fatal(err)
+		}
+		restored := &v1alpha2.AuthProvider{}
+		if err := spoke.ConvertTo(restored); err != nil {
+			t.Fatal(err)
+		}
+		if !equality.Semantic.DeepEqual(hub.Spec, restored.Spec) {
+			t.Errorf("round trip changed the %s AuthProvider:\n%+v\n%+v", hub.Spec.Type, hub.Spec, restored.Spec)
+		}
+		if _, ok := restored.Annotations[HubSpecAnnotation]; ok {
+			t.Errorf("expected the hub spec annotation to be removed from the %s AuthProvider", hub.Spec.Type)
+		}
+	}
+}
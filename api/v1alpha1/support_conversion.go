@@ -0,0 +1,219 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// HubSpecAnnotation keeps the v1alpha2 spec on v1alpha1 objects so values v1alpha1 cannot
+// express survive a round trip through it
+const HubSpecAnnotation = "argosupport.argoproj.extensions.io/v1alpha2-spec"
+
+var _ conversion.Convertible = &Support{}
+
+// ConvertTo converts this Support to the hub version
+func (src *Support) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha2.Support)
+	in := src.DeepCopy()
+
+	stored := &v1alpha2.SupportSpec{}
+	if data, ok := in.Annotations[HubSpecAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), stored); err != nil {
+			return err
+		}
+		delete(in.Annotations, HubSpecAnnotation)
+	}
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = v1alpha2.SupportSpec{
+		RunID:              stored.RunID,
+		ResultHistoryLimit: stored.ResultHistoryLimit,
+		Suspend:            stored.Suspend,
+	}
+
+	if in.Spec.Target != nil {
+		target := v1alpha2.TargetRef(*in.Spec.Target)
+		dst.Spec.Target = &target
+	}
+	for _, wf := range in.Spec.Workflows {
+		out := v1alpha2.Workflow{
+			Name:        wf.Name,
+			InitiatedAt: wf.InitiatedAt,
+			RetryLimit:  wf.RetryLimit,
+		}
+		previous := storedWorkflow(stored, wf.Name)
+		if previous != nil {
+			out.DependsOn = previous.DependsOn
+		}
+		for _, ref := range wf.Ref {
+			out.AuthProviderRefs = append(out.AuthProviderRefs, v1alpha2.NamespacedObjectReference(ref))
+		}
+		if wf.ConfigMapRef.Name != "" {
+			out.ConfigMapRef = &v1alpha2.ConfigMapRef{Name: wf.ConfigMapRef.Name}
+		}
+		if wf.Delay != 0 {
+			out.Delay = &metav1.Duration{Duration: time.Duration(wf.Delay) * time.Second}
+			// keep the sub-second part of a delay set through v1alpha2
+			if previous != nil && previous.Delay != nil &&
+				previous.Delay.Duration.Truncate(time.Second) == out.Delay.Duration {
+				out.Delay = previous.Delay
+			}
+		}
+		dst.Spec.Workflows = append(dst.Spec.Workflows, out)
+	}
+
+	dst.Status = v1alpha2.SupportStatus{
+		LastTransitionTime: in.Status.LastTransitionTime,
+		ObservedGeneration: in.Status.ObservedGeneration,
+		Phase:              v1alpha2.ArgoSupportPhase(in.Status.Phase),
+		Conditions:         in.Status.Conditions,
+	}
+	for _, result := range in.Status.Results {
+		dst.Status.Results = append(dst.Status.Results, v1alpha2.Result{
+			Name:       result.Name,
+			StartedAt:  result.StartedAt,
+			FinishedAt: result.FinishedAt,
+			Summary:    result.Summary.MainSummary,
+			Analysis:   analysisToHub(result.Analysis),
+			Message:    result.Message,
+			Phase:      v1alpha2.ArgoSupportPhase(result.Phase),
+			Help:       v1alpha2.Help(result.Help),
+			Feedback:   v1alpha2.Feedback(result.Feedback),
+		})
+	}
+	for _, wfStatus := range in.Status.Workflows {
+		dst.Status.Workflows = append(dst.Status.Workflows, v1alpha2.WorkflowStatus{
+			Name:               wfStatus.Name,
+			EffectiveConfig:    wfStatus.EffectiveConfig,
+			RunID:              wfStatus.RunID,
+			Phase:              v1alpha2.ArgoSupportPhase(wfStatus.Phase),
+			Attempts:           wfStatus.Attempts,
+			ObservedGeneration: wfStatus.ObservedGeneration,
+			NextRetryTime:      wfStatus.NextRetryTime,
+			Message:            wfStatus.Message,
+			ResultRefs:         wfStatus.ResultRefs,
+		})
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version to this version
+func (dst *Support) ConvertFrom(srcRaw conversion.Hub) error {
+	in := srcRaw.(*v1alpha2.Support).DeepCopy()
+
+	data, err := json.Marshal(in.Spec)
+	if err != nil {
+		return err
+	}
+	dst.ObjectMeta = in.ObjectMeta
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[HubSpecAnnotation] = string(data)
+	dst.Spec = SupportSpec{}
+
+	if in.Spec.Target != nil {
+		target := TargetRef(*in.Spec.Target)
+		dst.Spec.Target = &target
+	}
+	for _, wf := range in.Spec.Workflows {
+		out := Workflow{
+			Name:        wf.Name,
+			InitiatedAt: wf.InitiatedAt,
+			RetryLimit:  wf.RetryLimit,
+		}
+		for _, ref := range wf.AuthProviderRefs {
+			out.Ref = append(out.Ref, NamespacedObjectReference(ref))
+		}
+		if wf.ConfigMapRef != nil {
+			out.ConfigMapRef = ConfigMapRef{Name: wf.ConfigMapRef.Name}
+		}
+		if wf.Delay != nil {
+			out.Delay = int(wf.Delay.Duration / time.Second)
+		}
+		dst.Spec.Workflows = append(dst.Spec.Workflows, out)
+	}
+
+	dst.Status = SupportStatus{
+		LastTransitionTime: in.Status.LastTransitionTime,
+		ObservedGeneration: in.Status.ObservedGeneration,
+		Phase:              ArgoSupportPhase(in.Status.Phase),
+		Conditions:         in.Status.Conditions,
+	}
+	for _, result := range in.Status.Results {
+		dst.Status.Results = append(dst.Status.Results, Result{
+			Name:       result.Name,
+			StartedAt:  result.StartedAt,
+			FinishedAt: result.FinishedAt,
+			Summary:    Summary{MainSummary: result.Summary},
+			Analysis:   analysisFromHub(result.Analysis),
+			Message:    result.Message,
+			Phase:      ArgoSupportPhase(result.Phase),
+			Help:       Help(result.Help),
+			Feedback:   Feedback(result.Feedback),
+		})
+	}
+	for _, wfStatus := range in.Status.Workflows {
+		dst.Status.Workflows = append(dst.Status.Workflows, WorkflowStatus{
+			Name:               wfStatus.Name,
+			EffectiveConfig:    wfStatus.EffectiveConfig,
+			RunID:              wfStatus.RunID,
+			Phase:              ArgoSupportPhase(wfStatus.Phase),
+			Attempts:           wfStatus.Attempts,
+			ObservedGeneration: wfStatus.ObservedGeneration,
+			NextRetryTime:      wfStatus.NextRetryTime,
+			Message:            wfStatus.Message,
+			ResultRefs:         wfStatus.ResultRefs,
+		})
+		// count is the number of attempts made for the current runs
+		dst.Status.Count += wfStatus.Attempts
+	}
+	return nil
+}
+
+// storedWorkflow returns the named workflow of the spec kept in the hub spec annotation
+func storedWorkflow(spec *v1alpha2.SupportSpec, name string) *v1alpha2.Workflow {
+	for i := range spec.Workflows {
+		if spec.Workflows[i].Name == name {
+			return &spec.Workflows[i]
+		}
+	}
+	return nil
+}
+
+func analysisToHub(in *Analysis) *v1alpha2.Analysis {
+	if in == nil {
+		return nil
+	}
+	out := &v1alpha2.Analysis{
+		RootCause:        in.RootCause,
+		Category:         v1alpha2.FailureCategory(in.Category),
+		RemediationSteps: in.RemediationSteps,
+		Confidence:       v1alpha2.ConfidenceLevel(in.Confidence),
+		Evidence:         in.Evidence,
+	}
+	for _, ref := range in.AffectedResources {
+		out.AffectedResources = append(out.AffectedResources, v1alpha2.ObjectReference(ref))
+	}
+	return out
+}
+
+func analysisFromHub(in *v1alpha2.Analysis) *Analysis {
+	if in == nil {
+		return nil
+	}
+	out := &Analysis{
+		RootCause:        in.RootCause,
+		Category:         FailureCategory(in.Category),
+		RemediationSteps: in.RemediationSteps,
+		Confidence:       ConfidenceLevel(in.Confidence),
+		Evidence:         in.Evidence,
+	}
+	for _, ref := range in.AffectedResources {
+		out.AffectedResources = append(out.AffectedResources, ObjectReference(ref))
+	}
+	return out
+}
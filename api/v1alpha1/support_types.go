@@ -17,7 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -30,6 +32,31 @@ type SupportSpec struct {
 
 	// Foo is an example field of Support. Edit support_types.go to remove/update
 	Workflows []Workflow `json:"workflows,omitempty"`
+	// Target is the object the workflows analyze. When it is not set the Rollouts in the
+	// namespace of the Support and the app.kubernetes.io/instance label are used instead.
+	// +kubebuilder:validation:Optional
+	Target *TargetRef `json:"target,omitempty"`
+}
+
+// TargetRef identifies the object a Support analyzes
+type TargetRef struct {
+	// Group of the target, empty for the core API group
+	Group string `json:"group,omitempty"`
+	// +kubebuilder:validation:Required
+	Version string `json:"version"`
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Namespace of the target, which has to be the namespace of the Support when set
+	Namespace string `json:"namespace,omitempty"`
+	// Application is the Argo CD application the target belongs to
+	Application string `json:"application,omitempty"`
+}
+
+// GroupVersionKind returns the kind of the target
+func (t *TargetRef) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: t.Group, Version: t.Version, Kind: t.Kind}
 }
 
 // SupportStatus defines the observed state of Support
@@ -43,6 +70,39 @@ type SupportStatus struct {
 	// +kubebuilder:validation:Optional
 	ObservedGeneration int64            `json:"observedGeneration,omitempty"`
 	Phase              ArgoSupportPhase `json:"phase,omitempty"`
+	// Conditions describe why the latest run of the workflows succeeded or failed
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Workflows holds the observed state of each workflow in spec.workflows
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:Optional
+	Workflows []WorkflowStatus `json:"workflows,omitempty"`
+}
+
+// WorkflowStatus defines the observed state of a single workflow
+type WorkflowStatus struct {
+	// Name of the workflow in spec.workflows
+	Name string `json:"name"`
+	// EffectiveConfig is the workflow ConfigMap layered over the controller defaults, as used by the last run
+	EffectiveConfig map[string]string `json:"effectiveConfig,omitempty"`
+	// RunID identifies the run the workflow last processed
+	RunID string `json:"runID,omitempty"`
+	// Phase of the run identified by RunID
+	Phase ArgoSupportPhase `json:"phase,omitempty"`
+	// Attempts is the number of attempts made for the run identified by RunID
+	Attempts int64 `json:"attempts,omitempty"`
+	// ObservedGeneration is the generation of the spec the last attempt ran with
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// NextRetryTime is when the failed run is attempted again
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+	// Message explains the phase, such as the reason a run was cancelled
+	Message string `json:"message,omitempty"`
+	// ResultRefs are the names of the results the run identified by RunID produced, in
+	// status.results or archived as SupportResults
+	ResultRefs []string `json:"resultRefs,omitempty"`
 }
 
 type Feedback struct {
@@ -68,10 +128,72 @@ type Result struct {
 	StartedAt  *metav1.Time `json:"startedAt,omitempty"`
 	Summary    Summary      `json:"summary,omitempty"`
 	Message    string       `json:"message,omitempty"`
+	// Phase is Cancelled for the result recorded when a run is cancelled, the reason is in Message,
+	// and empty for an analysis
+	Phase ArgoSupportPhase `json:"phase,omitempty"`
+	// Analysis holds the parts of the answer of the provider
+	// +kubebuilder:validation:Optional
+	Analysis *Analysis `json:"analysis,omitempty"`
+}
+
+// FailureCategory classifies the probable cause of a failure
+type FailureCategory string
+
+// Well known FailureCategory values, providers may report others
+const (
+	FailureCategoryConfiguration FailureCategory = "Configuration"
+	FailureCategoryImage         FailureCategory = "Image"
+	FailureCategoryResources     FailureCategory = "Resources"
+	FailureCategoryHealthCheck   FailureCategory = "HealthCheck"
+	FailureCategoryNetwork       FailureCategory = "Network"
+	FailureCategoryDependency    FailureCategory = "Dependency"
+	FailureCategoryApplication   FailureCategory = "Application"
+	FailureCategoryUnknown       FailureCategory = "Unknown"
+)
+
+// ConfidenceLevel is how sure the provider is of its analysis
+// +kubebuilder:validation:Enum=low;medium;high
+type ConfidenceLevel string
+
+// Possible ConfidenceLevel values
+const (
+	ConfidenceLow    ConfidenceLevel = "low"
+	ConfidenceMedium ConfidenceLevel = "medium"
+	ConfidenceHigh   ConfidenceLevel = "high"
+)
+
+// ObjectReference identifies an object named in an analysis
+type ObjectReference struct {
+	// Group of the object, empty for the core API group
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Analysis is the structured form of the answer of a provider
+type Analysis struct {
+	// RootCause is the probable root cause of the failure
+	RootCause string `json:"rootCause,omitempty"`
+	// Category classifies the root cause
+	Category FailureCategory `json:"category,omitempty"`
+	// AffectedResources are the objects involved in the failure
+	AffectedResources []ObjectReference `json:"affectedResources,omitempty"`
+	// RemediationSteps are the suggested fixes, in order
+	RemediationSteps []string `json:"remediationSteps,omitempty"`
+	// Confidence is how sure the provider is of the root cause
+	// +kubebuilder:validation:Optional
+	Confidence ConfidenceLevel `json:"confidence,omitempty"`
+	// Evidence are the snippets of the collected context the analysis is based on
+	Evidence []string `json:"evidence,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Succeeded",type="string",JSONPath=".status.conditions[?(@.type=='AnalysisSucceeded')].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type=='AnalysisSucceeded')].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Support is the Schema for the supports API
 type Support struct {
@@ -82,6 +204,17 @@ type Support struct {
 	Status SupportStatus `json:"status,omitempty"`
 }
 
+// SetCondition sets the condition of the given type on the status, stamped with the current generation
+func (s *Support) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: s.Generation,
+	})
+}
+
 //+kubebuilder:object:root=true
 
 // SupportList contains a list of Support
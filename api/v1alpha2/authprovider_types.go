@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuthProviderSpec defines the desired state of AuthProvider
+type AuthProviderSpec struct {
+	// Type selects the backend the AuthProvider connects to and the client built for it
+	// +kubebuilder:validation:Required
+	Type AuthProviderType `json:"type"`
+	// SecretRef names the Secret holding the credentials of the provider
+	SecretRef *v1.LocalObjectReference `json:"secretRef,omitempty"`
+	// Auth holds the connection settings shared by all types
+	// +kubebuilder:validation:Required
+	Auth Auth `json:"auth"`
+	// GenAI holds the settings used when type is genai
+	// +kubebuilder:validation:Optional
+	GenAI *GenAISettings `json:"genai,omitempty"`
+	// ArgoCD holds the settings used when type is argocd
+	// +kubebuilder:validation:Optional
+	ArgoCD *ArgoCDSettings `json:"argocd,omitempty"`
+	// Webhook holds the settings used when type is webhook
+	// +kubebuilder:validation:Optional
+	Webhook *WebhookSettings `json:"webhook,omitempty"`
+	// Plugin holds the settings used when type is plugin
+	// +kubebuilder:validation:Optional
+	Plugin *PluginSettings `json:"plugin,omitempty"`
+	// OpenAI holds the settings used when type is openai
+	// +kubebuilder:validation:Optional
+	OpenAI *LLMSettings `json:"openai,omitempty"`
+	// Anthropic holds the settings used when type is anthropic
+	// +kubebuilder:validation:Optional
+	Anthropic *LLMSettings `json:"anthropic,omitempty"`
+	// Ollama holds the settings used when type is ollama
+	// +kubebuilder:validation:Optional
+	Ollama *OllamaSettings `json:"ollama,omitempty"`
+	// TLS configures how connections to the provider are verified and authenticated
+	// +kubebuilder:validation:Optional
+	TLS *TLSSettings `json:"tls,omitempty"`
+	// ProxyURL is the HTTP or HTTPS proxy requests to the provider are sent through. When not set,
+	// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables of the controller apply.
+	// +kubebuilder:validation:Optional
+	ProxyURL string `json:"proxyUrl,omitempty"`
+	// AllowedNamespaces are the namespaces, besides its own, whose Supports may use the AuthProvider,
+	// "*" allows every namespace. Only Supports in its own namespace may use it when not set.
+	// +kubebuilder:validation:Optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+// ServesWorkflow reports whether an AuthProvider of type plugin runs the named workflow
+func (s *AuthProviderSpec) ServesWorkflow(name string) bool {
+	if s.Type != AuthProviderTypePlugin {
+		return false
+	}
+	if s.Plugin == nil || len(s.Plugin.Workflows) == 0 {
+		return true
+	}
+	return slices.Contains(s.Plugin.Workflows, name)
+}
+
+// AllowsNamespace reports whether Supports in the namespace may use the AuthProvider
+func (a *AuthProvider) AllowsNamespace(namespace string) bool {
+	return namespace == a.Namespace || slices.Contains(a.Spec.AllowedNamespaces, namespace) ||
+		slices.Contains(a.Spec.AllowedNamespaces, AllNamespaces)
+}
+
+// AuthProviderStatus defines the observed state of AuthProvider
+type AuthProviderStatus struct {
+	// Conditions reports the outcome of the latest secret and endpoint checks
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastCheckedTime is the time the secret and endpoint were last verified
+	LastCheckedTime *metav1.Time `json:"lastCheckedTime,omitempty"`
+	// The generation observed by the controller from metadata.generation
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="Last Checked",type="date",JSONPath=".status.lastCheckedTime"
+
+// AuthProvider is the Schema for the authproviders API
+type AuthProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AuthProviderSpec   `json:"spec,omitempty"`
+	Status AuthProviderStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AuthProviderList contains a list of AuthProvider
+type AuthProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AuthProvider `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AuthProvider{}, &AuthProviderList{})
+}
@@ -0,0 +1,368 @@
+package v1alpha2
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LabelKeyAppName is the label key to identify the authprovider
+	LabelKeyAppName      = "app.kubernetes.io/name"
+	LabelKeyAppNameValue = "argo-support"
+	FinalizerName        = "support.argoproj.extensions.io/finalizer"
+
+	// LabelKeySupportName is the label key holding the name of the Support a SupportResult belongs to
+	LabelKeySupportName = "argosupport.argoproj.extensions.io/support"
+
+	// AnnotationKeyCancel cancels the running analyses of a Support and keeps its workflows from
+	// running while it is set, its value is recorded as the reason
+	AnnotationKeyCancel = "support.argoproj.extensions.io/cancel"
+
+	// AllNamespaces in spec.allowedNamespaces of an AuthProvider lets Supports in every namespace use it
+	AllNamespaces = "*"
+)
+
+type ArgoSupportPhase string
+
+// Possible ArgoSupportPhase values
+const (
+	ArgoSupportPhasePending   ArgoSupportPhase = "pending"
+	ArgoSupportPhaseRunning   ArgoSupportPhase = "running"
+	ArgoSupportPhaseCompleted ArgoSupportPhase = "completed"
+	ArgoSupportPhaseFailed    ArgoSupportPhase = "failed"
+	ArgoSupportPhaseError     ArgoSupportPhase = "error"
+	ArgoSupportPhaseCancelled ArgoSupportPhase = "cancelled"
+)
+
+// AuthProvider condition types
+const (
+	// AuthProviderConditionReady is true when the secret is present and the endpoint answered the probe
+	AuthProviderConditionReady = "Ready"
+	// AuthProviderConditionSecretMissing is true when the referenced Secret or its app.secret key is missing
+	AuthProviderConditionSecretMissing = "SecretMissing"
+	// AuthProviderConditionUnreachable is true when the endpoint could not be probed successfully
+	AuthProviderConditionUnreachable = "Unreachable"
+)
+
+// AuthProvider condition reasons
+const (
+	AuthProviderReasonVerified            = "Verified"
+	AuthProviderReasonSecretRefMissing    = "SecretRefMissing"
+	AuthProviderReasonSecretNotFound      = "SecretNotFound"
+	AuthProviderReasonSecretKeyNotFound   = "SecretKeyNotFound"
+	AuthProviderReasonSecretFound         = "SecretFound"
+	AuthProviderReasonInvalidEndpoint     = "InvalidEndpoint"
+	AuthProviderReasonEndpointUnreachable = "EndpointUnreachable"
+	AuthProviderReasonEndpointReachable   = "EndpointReachable"
+	AuthProviderReasonNotVerified         = "NotVerified"
+	AuthProviderReasonInvalidTLS          = "InvalidTLS"
+)
+
+// Support condition types
+const (
+	// SupportConditionContextCollected is true when the context of the target was collected
+	SupportConditionContextCollected = "ContextCollected"
+	// SupportConditionProviderReachable is true when the AuthProviders resolved and the LLM provider answered
+	SupportConditionProviderReachable = "ProviderReachable"
+	// SupportConditionAnalysisSucceeded is true when the latest analysis produced a result
+	SupportConditionAnalysisSucceeded = "AnalysisSucceeded"
+	// SupportConditionStale is true when the spec changed after the latest result was produced
+	SupportConditionStale = "Stale"
+)
+
+// Support condition reasons
+const (
+	SupportReasonCollected            = "Collected"
+	SupportReasonCollectionFailed     = "CollectionFailed"
+	SupportReasonReachable            = "Reachable"
+	SupportReasonAuthProviderNotFound = "AuthProviderNotFound"
+	SupportReasonProviderUnavailable  = "ProviderUnavailable"
+	SupportReasonRequestFailed        = "RequestFailed"
+	SupportReasonSucceeded            = "Succeeded"
+	SupportReasonInvalidResponse      = "InvalidResponse"
+	SupportReasonConfigurationError   = "ConfigurationError"
+	SupportReasonUnknownWorkflow      = "UnknownWorkflow"
+	SupportReasonRetryLimitReached    = "RetryLimitReached"
+	SupportReasonPermanentFailure     = "PermanentFailure"
+	SupportReasonCancelled            = "Cancelled"
+	SupportReasonSpecChanged          = "SpecChanged"
+	SupportReasonUpToDate             = "UpToDate"
+	SupportReasonAuthProviderChanged  = "AuthProviderChanged"
+)
+
+// AuthProviderType identifies the backend an AuthProvider connects to
+// +kubebuilder:validation:Enum=genai;argocd;webhook;plugin;openai;anthropic;ollama
+type AuthProviderType string
+
+// Possible AuthProviderType values
+const (
+	// AuthProviderTypeGenAI is the GenAI analyze service authenticated through the identity service
+	AuthProviderTypeGenAI AuthProviderType = "genai"
+	// AuthProviderTypeArgoCD is an Argo CD API server
+	AuthProviderTypeArgoCD AuthProviderType = "argocd"
+	// AuthProviderTypeWebhook is a self-hosted service speaking the GenAI analyze protocol
+	AuthProviderTypeWebhook AuthProviderType = "webhook"
+	// AuthProviderTypePlugin is an external service running workflows through the executor plugin protocol
+	AuthProviderTypePlugin AuthProviderType = "plugin"
+	// AuthProviderTypeOpenAI is a server speaking the OpenAI chat completions API, such as OpenAI, vLLM or LocalAI
+	AuthProviderTypeOpenAI AuthProviderType = "openai"
+	// AuthProviderTypeAnthropic is the Anthropic Messages API
+	AuthProviderTypeAnthropic AuthProviderType = "anthropic"
+	// AuthProviderTypeOllama is a self-hosted server speaking the Ollama chat API, which keeps the
+	// collected context inside the cluster
+	AuthProviderTypeOllama AuthProviderType = "ollama"
+)
+
+// ProviderRole is the part an AuthProvider plays in a workflow
+type ProviderRole string
+
+// Possible ProviderRole values
+const (
+	// ProviderRoleLLM analyzes the collected context
+	ProviderRoleLLM ProviderRole = "llm"
+	// ProviderRoleGitOps is the source of the application state
+	ProviderRoleGitOps ProviderRole = "gitops"
+	// ProviderRoleExecutor runs the workflow out of process
+	ProviderRoleExecutor ProviderRole = "executor"
+)
+
+// Role returns the role an AuthProvider of this type fills in a workflow
+func (t AuthProviderType) Role() ProviderRole {
+	switch t {
+	case AuthProviderTypeArgoCD:
+		return ProviderRoleGitOps
+	case AuthProviderTypeGenAI, AuthProviderTypeWebhook, AuthProviderTypeOpenAI, AuthProviderTypeAnthropic, AuthProviderTypeOllama:
+		return ProviderRoleLLM
+	case AuthProviderTypePlugin:
+		return ProviderRoleExecutor
+	default:
+		return ""
+	}
+}
+
+// GenAISettings configures the identity service flow of a genai AuthProvider
+type GenAISettings struct {
+	AppID            string `json:"appId,omitempty"`
+	IdentityEndpoint string `json:"identityEndpoint,omitempty"`
+	IdentityJobID    string `json:"identityJobID,omitempty"`
+	APIVersion       string `json:"apiVersion,omitempty"`
+}
+
+// ArgoCDSettings configures an argocd AuthProvider
+type ArgoCDSettings struct {
+	// AppNamespace is the namespace of the Argo CD Applications when apps in any namespace is enabled
+	AppNamespace string `json:"appNamespace,omitempty"`
+}
+
+// WebhookSettings configures a webhook AuthProvider
+type WebhookSettings struct {
+	// APIVersion is the path segment placed between the base URL and the analyze endpoint
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Headers are added to every request sent to the webhook
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// LLMSettings configures the model of an AuthProvider talking to an LLM API directly
+type LLMSettings struct {
+	// Model is the model the analyses are requested from
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Model string `json:"model"`
+	// Temperature is the sampling temperature, between 0 and 2 for openai and between 0 and 1 for
+	// anthropic, the server default when not set
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Temperature string `json:"temperature,omitempty"`
+	// MaxTokens limits the length of the answer, the server default when not set and 1024 for
+	// anthropic, which requires a limit
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxTokens int32 `json:"maxTokens,omitempty"`
+	// Headers are added to every request sent to the API
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is the deadline for an analysis, overriding the llm stage deadline of the controller
+	// for models that answer slower, 15m for ollama when not set
+	// +kubebuilder:validation:Optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// OllamaSettings configures an ollama AuthProvider
+type OllamaSettings struct {
+	LLMSettings `json:",inline"`
+	// PullModel pulls the model when the server does not have it yet instead of failing the analysis
+	// +kubebuilder:validation:Optional
+	PullModel bool `json:"pullModel,omitempty"`
+}
+
+// PluginSettings configures a plugin AuthProvider
+type PluginSettings struct {
+	// Workflows are the names of the workflows the plugin runs, every workflow referencing the
+	// AuthProvider without a compiled-in executor when empty
+	Workflows []string `json:"workflows,omitempty"`
+	// Headers are added to every request sent to the plugin
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Auth holds the connection settings shared by all AuthProvider types
+type Auth struct {
+	// BaseURL is the endpoint of the provider
+	// +kubebuilder:validation:Required
+	BaseURL string `json:"baseUrl"`
+	// Scheme selects how requests to the provider are authenticated. When not set, argocd sends
+	// the app.secret key as argocd.token cookie, anthropic as x-api-key header, genai exchanges it
+	// at the identity service of spec.genai when one is set, and all others send it as bearer token.
+	// +kubebuilder:validation:Optional
+	Scheme AuthScheme `json:"scheme,omitempty"`
+	// Bearer holds the settings used when scheme is bearer
+	// +kubebuilder:validation:Optional
+	Bearer *BearerAuth `json:"bearer,omitempty"`
+	// Basic holds the settings used when scheme is basic
+	// +kubebuilder:validation:Optional
+	Basic *BasicAuth `json:"basic,omitempty"`
+	// APIKey holds the settings used when scheme is apiKey
+	// +kubebuilder:validation:Optional
+	APIKey *APIKeyAuth `json:"apiKey,omitempty"`
+	// OAuth2 holds the settings used when scheme is oauth2
+	// +kubebuilder:validation:Optional
+	OAuth2 *OAuth2Auth `json:"oauth2,omitempty"`
+	// IdentityService holds the settings used when scheme is identityService
+	// +kubebuilder:validation:Optional
+	IdentityService *IdentityServiceAuth `json:"identityService,omitempty"`
+}
+
+// AuthScheme selects how the requests sent to a provider are authenticated
+// +kubebuilder:validation:Enum=bearer;basic;apiKey;oauth2;identityService
+type AuthScheme string
+
+// Possible AuthScheme values
+const (
+	// AuthSchemeBearer sends a static token as bearer token
+	AuthSchemeBearer AuthScheme = "bearer"
+	// AuthSchemeBasic sends a username and password with HTTP basic authentication
+	AuthSchemeBasic AuthScheme = "basic"
+	// AuthSchemeAPIKey sends a key in a header of a configurable name
+	AuthSchemeAPIKey AuthScheme = "apiKey"
+	// AuthSchemeOAuth2 obtains a bearer token from a token endpoint with the OAuth2 client credentials grant
+	AuthSchemeOAuth2 AuthScheme = "oauth2"
+	// AuthSchemeIdentityService exchanges app credentials for an authorization header at the identity service
+	AuthSchemeIdentityService AuthScheme = "identityService"
+)
+
+// BearerAuth configures the bearer scheme
+type BearerAuth struct {
+	// SecretKey is the key of the Secret holding the token, app.secret when not set
+	SecretKey string `json:"secretKey,omitempty"`
+}
+
+// BasicAuth configures the basic scheme
+type BasicAuth struct {
+	// UsernameKey is the key of the Secret holding the username, username when not set
+	UsernameKey string `json:"usernameKey,omitempty"`
+	// PasswordKey is the key of the Secret holding the password, password when not set
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// APIKeyAuth configures the apiKey scheme
+type APIKeyAuth struct {
+	// Header is the name of the header carrying the key, e.g. X-API-Key
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Header string `json:"header"`
+	// Prefix is placed before the key in the header value, separated by a space
+	Prefix string `json:"prefix,omitempty"`
+	// SecretKey is the key of the Secret holding the API key, app.secret when not set
+	SecretKey string `json:"secretKey,omitempty"`
+}
+
+// OAuth2Auth configures the oauth2 scheme
+type OAuth2Auth struct {
+	// TokenURL is the token endpoint of the authorization server
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	TokenURL string `json:"tokenUrl"`
+	// Scopes are requested with the token
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientIDKey is the key of the Secret holding the client ID, client-id when not set
+	ClientIDKey string `json:"clientIdKey,omitempty"`
+	// ClientSecretKey is the key of the Secret holding the client secret, client-secret when not set
+	ClientSecretKey string `json:"clientSecretKey,omitempty"`
+}
+
+// IdentityServiceAuth configures the identityService scheme. Fields that are not set fall back to
+// the identity settings in spec.genai.
+type IdentityServiceAuth struct {
+	// Endpoint is the base URL of the identity service
+	Endpoint string `json:"endpoint,omitempty"`
+	// AppID identifies the application signing in
+	AppID string `json:"appId,omitempty"`
+	// ProfileID is the profile the application signs in with
+	ProfileID string `json:"profileId,omitempty"`
+	// SecretKey is the key of the Secret holding the app secret, app.secret when not set
+	SecretKey string `json:"secretKey,omitempty"`
+}
+
+// TLSSettings configures the TLS connections to a provider
+type TLSSettings struct {
+	// CABundle selects the PEM encoded certificates of the authorities the server certificate is
+	// verified against, in addition to the system roots
+	// +kubebuilder:validation:Optional
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+	// ClientCertificate names a Secret of type kubernetes.io/tls in the namespace of the
+	// AuthProvider whose tls.crt and tls.key authenticate the controller to the server
+	// +kubebuilder:validation:Optional
+	ClientCertificate *v1.LocalObjectReference `json:"clientCertificate,omitempty"`
+	// InsecureSkipVerify accepts any server certificate. It is meant for development only.
+	// +kubebuilder:validation:Optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// CABundleSource selects the key of a ConfigMap or a Secret in the namespace of the AuthProvider
+// holding a CA bundle, exactly one of them has to be set
+type CABundleSource struct {
+	// ConfigMapKeyRef selects the key of a ConfigMap holding the bundle
+	// +kubebuilder:validation:Optional
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects the key of a Secret holding the bundle
+	// +kubebuilder:validation:Optional
+	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// Workflow selects an analysis run against the target of a Support
+type Workflow struct {
+	// Name selects the workflow executor
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// InitiatedAt is the time the workflow was requested
+	// +kubebuilder:validation:Optional
+	InitiatedAt *metav1.Time `json:"initiatedAt,omitempty"`
+	// AuthProviderRefs are the AuthProviders the workflow connects through
+	// +kubebuilder:validation:Required
+	AuthProviderRefs []NamespacedObjectReference `json:"authProviderRefs"`
+	// ConfigMapRef names the ConfigMap layered over the controller defaults
+	// +kubebuilder:validation:Optional
+	ConfigMapRef *ConfigMapRef `json:"configMapRef,omitempty"`
+	// RetryLimit is the number of attempts the workflow gets
+	// +kubebuilder:validation:Optional
+	RetryLimit int64 `json:"retryLimit,omitempty"`
+	// Delay is the time to wait between attempts
+	// +kubebuilder:validation:Optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+	// DependsOn names the workflows of the Support that have to complete their run before this
+	// one starts. Their latest results are handed to the executor of this workflow.
+	// +kubebuilder:validation:Optional
+	// +listType=set
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// NamespacedObjectReference refers to an object by name, in the namespace of the referrer when none is given
+type NamespacedObjectReference struct {
+	// +kubebuilder:validation:Required
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ConfigMapRef refers to a ConfigMap in the namespace of the referrer
+type ConfigMapRef struct {
+	// Name of the ConfigMap
+	Name string `json:"name"`
+}
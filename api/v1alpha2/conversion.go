@@ -0,0 +1,7 @@
+package v1alpha2
+
+// Hub marks Support as the version the other versions convert through
+func (*Support) Hub() {}
+
+// Hub marks AuthProvider as the version the other versions convert through
+func (*AuthProvider) Hub() {}
@@ -0,0 +1,236 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SupportSpec defines the desired state of Support
+type SupportSpec struct {
+	// Workflows are the analyses run against the target
+	Workflows []Workflow `json:"workflows,omitempty"`
+	// Target is the object the workflows analyze. When it is not set the Rollouts in the
+	// namespace of the Support and the app.kubernetes.io/instance label are used instead.
+	// +kubebuilder:validation:Optional
+	Target *TargetRef `json:"target,omitempty"`
+	// RunID identifies the requested run of the workflows. Changing it runs every workflow again.
+	// When it is not set the initiatedAt of each workflow identifies its run.
+	// +kubebuilder:validation:Optional
+	RunID string `json:"runID,omitempty"`
+	// ResultHistoryLimit is the number of results kept in the status. Older results are moved
+	// to SupportResult objects. The controller-wide limit is used when it is not set.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	ResultHistoryLimit *int32 `json:"resultHistoryLimit,omitempty"`
+	// Suspend cancels the running analyses and keeps the workflows from running until it is unset
+	// +kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// TargetRef identifies the object a Support analyzes
+type TargetRef struct {
+	// Group of the target, empty for the core API group
+	Group string `json:"group,omitempty"`
+	// +kubebuilder:validation:Required
+	Version string `json:"version"`
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Namespace of the target, which has to be the namespace of the Support when set
+	Namespace string `json:"namespace,omitempty"`
+	// Application is the Argo CD application the target belongs to
+	Application string `json:"application,omitempty"`
+}
+
+// GroupVersionKind returns the kind of the target
+func (t *TargetRef) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: t.Group, Version: t.Version, Kind: t.Kind}
+}
+
+// SupportStatus defines the observed state of Support
+type SupportStatus struct {
+	// Results are the latest results, newest first
+	Results []Result `json:"results,omitempty"`
+	// LastTransitionTime is the time the phase last changed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The generation observed by the controller from metadata.generation
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64            `json:"observedGeneration,omitempty"`
+	Phase              ArgoSupportPhase `json:"phase,omitempty"`
+	// Conditions describe why the latest run of the workflows succeeded or failed
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Workflows holds the observed state of each workflow in spec.workflows
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:Optional
+	Workflows []WorkflowStatus `json:"workflows,omitempty"`
+}
+
+// WorkflowStatus defines the observed state of a single workflow
+type WorkflowStatus struct {
+	// Name of the workflow in spec.workflows
+	Name string `json:"name"`
+	// EffectiveConfig is the workflow ConfigMap layered over the controller defaults, as used by the last run
+	EffectiveConfig map[string]string `json:"effectiveConfig,omitempty"`
+	// RunID identifies the run the workflow last processed
+	RunID string `json:"runID,omitempty"`
+	// Phase of the run identified by RunID
+	Phase ArgoSupportPhase `json:"phase,omitempty"`
+	// Attempts is the number of attempts made for the run identified by RunID
+	Attempts int64 `json:"attempts,omitempty"`
+	// ObservedGeneration is the generation of the spec the last attempt ran with
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// NextRetryTime is when the failed run is attempted again
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+	// Message explains the phase, such as the reason a run was cancelled
+	Message string `json:"message,omitempty"`
+	// ResultRefs are the names of the results the run identified by RunID produced, in
+	// status.results or archived as SupportResults
+	ResultRefs []string `json:"resultRefs,omitempty"`
+}
+
+// Feedback is the rating a user gave a result
+type Feedback struct {
+	DownVote    bool   `json:"downVote,omitempty"`
+	FeedbackMsg string `json:"feedbackMsg,omitempty"`
+	UpVote      bool   `json:"upVote,omitempty"`
+}
+
+// Help points to where more help with a result is available
+type Help struct {
+	Links        []string `json:"links,omitempty"`
+	SlackChannel string   `json:"slackChannel,omitempty"`
+}
+
+// Result is the outcome of a single workflow run
+type Result struct {
+	Name       string       `json:"name,omitempty"`
+	StartedAt  *metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+	// Summary is the analysis returned by the provider, as free text when it could not be structured
+	Summary string `json:"summary,omitempty"`
+	// Analysis holds the parts of the answer of the provider
+	// +kubebuilder:validation:Optional
+	Analysis *Analysis `json:"analysis,omitempty"`
+	// Message describes what was analyzed
+	Message  string   `json:"message,omitempty"`
+	Help     Help     `json:"help,omitempty"`
+	Feedback Feedback `json:"feedback,omitempty"`
+	// Phase is Cancelled for the result recorded when a run is cancelled, the reason is in Message,
+	// and empty for an analysis
+	Phase ArgoSupportPhase `json:"phase,omitempty"`
+}
+
+// FailureCategory classifies the probable cause of a failure
+type FailureCategory string
+
+// Well known FailureCategory values, providers may report others
+const (
+	FailureCategoryConfiguration FailureCategory = "Configuration"
+	FailureCategoryImage         FailureCategory = "Image"
+	FailureCategoryResources     FailureCategory = "Resources"
+	FailureCategoryHealthCheck   FailureCategory = "HealthCheck"
+	FailureCategoryNetwork       FailureCategory = "Network"
+	FailureCategoryDependency    FailureCategory = "Dependency"
+	FailureCategoryApplication   FailureCategory = "Application"
+	FailureCategoryUnknown       FailureCategory = "Unknown"
+)
+
+// ConfidenceLevel is how sure the provider is of its analysis
+// +kubebuilder:validation:Enum=low;medium;high
+type ConfidenceLevel string
+
+// Possible ConfidenceLevel values
+const (
+	ConfidenceLow    ConfidenceLevel = "low"
+	ConfidenceMedium ConfidenceLevel = "medium"
+	ConfidenceHigh   ConfidenceLevel = "high"
+)
+
+// ObjectReference identifies an object named in an analysis
+type ObjectReference struct {
+	// Group of the object, empty for the core API group
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Analysis is the structured form of the answer of a provider
+type Analysis struct {
+	// RootCause is the probable root cause of the failure
+	RootCause string `json:"rootCause,omitempty"`
+	// Category classifies the root cause
+	Category FailureCategory `json:"category,omitempty"`
+	// AffectedResources are the objects involved in the failure
+	AffectedResources []ObjectReference `json:"affectedResources,omitempty"`
+	// RemediationSteps are the suggested fixes, in order
+	RemediationSteps []string `json:"remediationSteps,omitempty"`
+	// Confidence is how sure the provider is of the root cause
+	// +kubebuilder:validation:Optional
+	Confidence ConfidenceLevel `json:"confidence,omitempty"`
+	// Evidence are the snippets of the collected context the analysis is based on
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Succeeded",type="string",JSONPath=".status.conditions[?(@.type=='AnalysisSucceeded')].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type=='AnalysisSucceeded')].reason"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Support is the Schema for the supports API
+type Support struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SupportSpec   `json:"spec,omitempty"`
+	Status SupportStatus `json:"status,omitempty"`
+}
+
+// SetCondition sets the condition of the given type on the status, stamped with the current generation
+func (s *Support) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: s.Generation,
+	})
+}
+
+//+kubebuilder:object:root=true
+
+// SupportList contains a list of Support
+type SupportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Support `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Support{}, &SupportList{})
+}
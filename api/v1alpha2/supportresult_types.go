@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SupportResultSpec defines a result moved out of the status of a Support
+type SupportResultSpec struct {
+	// SupportName is the name of the Support that produced the result
+	// +kubebuilder:validation:Required
+	SupportName string `json:"supportName"`
+	// Result is the archived result
+	// +kubebuilder:validation:Required
+	Result Result `json:"result"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Support",type="string",JSONPath=".spec.supportName"
+//+kubebuilder:printcolumn:name="Category",type="string",JSONPath=".spec.result.analysis.category"
+//+kubebuilder:printcolumn:name="Finished",type="date",JSONPath=".spec.result.finishedAt"
+
+// SupportResult is the Schema for the supportresults API. It holds a result that no longer fits
+// the result history of its Support and is deleted together with the Support.
+type SupportResult struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SupportResultSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SupportResultList contains a list of SupportResult
+type SupportResultList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SupportResult `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SupportResult{}, &SupportResultList{})
+}
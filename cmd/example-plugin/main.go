@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command example-plugin is an executor plugin running the context-summary workflow. It answers
+// with the size of the collected context and the lines mentioning errors, and is meant as a starting
+// point for plugins doing real work. The plugin expects the app.secret of its AuthProvider Secret as
+// bearer token, read from the PLUGIN_TOKEN environment variable.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/pkg/plugin"
+)
+
+const (
+	workflowName = "context-summary"
+	maxFindings  = 10
+)
+
+func main() {
+	var addr string
+	flag.StringVar(&addr, "addr", ":8090", "The address the plugin serves the protocol on")
+	flag.Parse()
+
+	handler := &plugin.Handler{
+		Manifest: plugin.Manifest{
+			Name:             "example-plugin",
+			ProtocolVersions: []string{plugin.ProtocolV1},
+			Workflows:        []string{workflowName},
+		},
+		Token:   os.Getenv("PLUGIN_TOKEN"),
+		Analyze: analyze,
+	}
+	if handler.Token == "" {
+		log.Print("PLUGIN_TOKEN is not set, serving requests without authentication")
+	}
+
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("serving %s on %s", workflowName, addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func analyze(ctx context.Context, req *plugin.AnalyzeRequest) (*v1alpha2.Result, error) {
+	if req.Context == "" {
+		return nil, plugin.BadRequest(errors.New("no context was collected"))
+	}
+
+	var findings []string
+	for _, line := range strings.Split(req.Context, "\n") {
+		lower := strings.ToLower(line)
+		if strings.Contains(lower, "error") || strings.Contains(lower, "fail") {
+			findings = append(findings, strings.TrimSpace(line))
+		}
+		if len(findings) == maxFindings {
+			break
+		}
+	}
+
+	target := fmt.Sprintf("rollouts in namespace %s", req.Support.Namespace)
+	if req.Target != nil {
+		target = fmt.Sprintf("%s %s", req.Target.Kind, req.Target.Name)
+	}
+	summary := fmt.Sprintf("%d lines of context for %s, %d mention errors", strings.Count(req.Context, "\n")+1, target, len(findings))
+	now := time.Now().UTC()
+	return &v1alpha2.Result{
+		Name:    fmt.Sprintf("%s-%d", req.Workflow.Name, now.Unix()),
+		Summary: summary,
+		Analysis: &v1alpha2.Analysis{
+			Evidence: findings,
+		},
+		Message: fmt.Sprintf("%s summarized %s", workflowName, target),
+	}, nil
+}
@@ -22,22 +22,36 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	argosupportv1alpha1 "github.com/argoproj-labs/argo-support/api/v1alpha1"
+	argosupportv1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
 	"github.com/argoproj-labs/argo-support/internal/controller"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	supportwebhook "github.com/argoproj-labs/argo-support/internal/webhook"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	// Workflows register their executors when imported, add in-house workflows here
+	_ "github.com/argoproj-labs/argo-support/internal/wf_operations/genai"
+	_ "github.com/argoproj-labs/argo-support/internal/wf_operations/plugin"
+	"github.com/argoproj-labs/argo-support/internal/workerpool"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -50,6 +64,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(argosupportv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(argosupportv1alpha2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -59,6 +74,16 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var authProviderRecheckInterval time.Duration
+	var defaultConfigMap string
+	var defaultRetryLimit int64
+	var defaultDelay time.Duration
+	var defaultAuthProviderRefs string
+	var resultHistoryLimit int
+	var retryBackoff controller.RetryBackoff
+	var analysisWorkers int
+	var analysisWorkersPerNamespace int
+	var stageTimeouts wf_operations.StageTimeouts
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -68,6 +93,40 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&authProviderRecheckInterval, "auth-provider-recheck-interval", 5*time.Minute,
+		"How often the secret and endpoint of every AuthProvider are verified again")
+	flag.StringVar(&defaultConfigMap, "default-config-map", "argo-support-system/argo-support-config",
+		"The namespace/name of the ConfigMap holding the defaults the workflow ConfigMaps are layered over")
+	flag.Int64Var(&defaultRetryLimit, "default-retry-limit", 3,
+		"The retry limit given to Support workflows that do not set one")
+	flag.DurationVar(&defaultDelay, "default-delay", 30*time.Second,
+		"The delay given to Support workflows that do not set one")
+	flag.StringVar(&defaultAuthProviderRefs, "default-auth-provider-refs", "",
+		"Comma separated namespace/name AuthProviders used by Support workflows that reference none, "+
+			"AuthProviders outside the namespace of a Support have to allow it in spec.allowedNamespaces")
+	flag.IntVar(&resultHistoryLimit, "result-history-limit", controller.DefaultResultHistoryLimit,
+		"The number of results kept in the status of Supports that do not set spec.resultHistoryLimit, "+
+			"0 archives every result to a SupportResult")
+	flag.Float64Var(&retryBackoff.Factor, "retry-backoff-factor", controller.DefaultRetryBackoff.Factor,
+		"The factor the delay of a failed workflow grows by with every further attempt")
+	flag.Float64Var(&retryBackoff.Jitter, "retry-jitter", controller.DefaultRetryBackoff.Jitter,
+		"The fraction of the retry delay added at random to spread out retries")
+	flag.DurationVar(&retryBackoff.MaxDelay, "retry-max-delay", controller.DefaultRetryBackoff.MaxDelay,
+		"The maximum delay between two attempts of a failed workflow")
+	flag.IntVar(&analysisWorkers, "analysis-workers", 10,
+		"The number of workflow attempts run concurrently")
+	flag.IntVar(&analysisWorkersPerNamespace, "analysis-workers-per-namespace", 0,
+		"The number of workflow attempts of a single namespace run concurrently, 0 leaves it to the fair share")
+	flag.DurationVar(&stageTimeouts.ArgoCD, "argocd-timeout", wf_operations.DefaultStageTimeouts.ArgoCD,
+		"The deadline for fetching the Argo CD Application of a Support")
+	flag.DurationVar(&stageTimeouts.Collection, "collection-timeout", wf_operations.DefaultStageTimeouts.Collection,
+		"The deadline for collecting the cluster context of a Support")
+	flag.DurationVar(&stageTimeouts.Identity, "identity-timeout", wf_operations.DefaultStageTimeouts.Identity,
+		"The deadline for obtaining an authorization header from the identity service")
+	flag.DurationVar(&stageTimeouts.LLM, "llm-timeout", wf_operations.DefaultStageTimeouts.LLM,
+		"The deadline for the analysis by the LLM provider, unless its AuthProvider sets spec.<type>.timeout")
+	flag.DurationVar(&stageTimeouts.Plugin, "plugin-timeout", wf_operations.DefaultStageTimeouts.Plugin,
+		"The deadline for the result of an executor plugin")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -76,6 +135,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if resultHistoryLimit < 0 {
+		setupLog.Error(nil, "result-history-limit must not be negative", "resultHistoryLimit", resultHistoryLimit)
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -105,6 +169,11 @@ func main() {
 			SecureServing: secureMetrics,
 			TLSOpts:       tlsOpts,
 		},
+		// Secrets and ConfigMaps are read from the API server instead of caching every one of them in
+		// the cluster, the AuthProvider controller only watches their metadata
+		Client: client.Options{Cache: &client.CacheOptions{
+			DisableFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}},
+		}},
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
@@ -126,23 +195,51 @@ func main() {
 		os.Exit(1)
 	}
 
+	providerClients := ai_provider.NewClientPool()
 	if err = (&controller.AuthProviderReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		RecheckInterval: authProviderRecheckInterval,
+		ProviderClients: providerClients,
+		Recorder:        mgr.GetEventRecorderFor("authprovider-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AuthProvider")
 		os.Exit(1)
 	}
 	if err = (&controller.SupportReconciler{
 
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		DynamicClient: *dynamicClient,
-		KubeClient:    kubeClient,
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		DynamicClient:      *dynamicClient,
+		KubeClient:         kubeClient,
+		DefaultConfigMap:   parseNamespacedName(defaultConfigMap),
+		ResultHistoryLimit: ptr.To(int32(resultHistoryLimit)),
+		RetryBackoff:       retryBackoff,
+		StageTimeouts:      stageTimeouts,
+		Pool:               workerpool.New(analysisWorkers, analysisWorkersPerNamespace),
+		APIReader:          mgr.GetAPIReader(),
+		ProviderClients:    providerClients,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Support")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&supportwebhook.SupportWebhook{
+			Client: mgr.GetClient(),
+			Defaults: supportwebhook.SupportDefaults{
+				RetryLimit:       defaultRetryLimit,
+				Delay:            defaultDelay,
+				AuthProviderRefs: parseObjectReferences(defaultAuthProviderRefs),
+			},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Support")
+			os.Exit(1)
+		}
+		if err = (&supportwebhook.AuthProviderWebhook{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AuthProvider")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -160,3 +257,26 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// parseNamespacedName parses a namespace/name flag value
+func parseNamespacedName(value string) types.NamespacedName {
+	namespace, name, found := strings.Cut(value, "/")
+	if !found {
+		return types.NamespacedName{Name: value}
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+// parseObjectReferences parses a comma separated list of namespace/name flag values
+func parseObjectReferences(value string) []argosupportv1alpha2.NamespacedObjectReference {
+	var refs []argosupportv1alpha2.NamespacedObjectReference
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name := parseNamespacedName(item)
+		refs = append(refs, argosupportv1alpha2.NamespacedObjectReference{Name: name.Name, Namespace: name.Namespace})
+	}
+	return refs
+}
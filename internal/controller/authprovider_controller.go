@@ -18,44 +18,326 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	argosupportv1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+)
 
-	argosupportv1alpha1 "github.com/argoproj-labs/argo-support/api/v1alpha1"
+const (
+	// defaultRecheckInterval is how often an AuthProvider is verified when no interval is configured
+	defaultRecheckInterval = 5 * time.Minute
+	// secretRefIndexKey indexes AuthProviders by the names of the Secrets they reference for
+	// credentials and TLS material
+	secretRefIndexKey = ".spec.secretRef.name"
+	// configMapRefIndexKey indexes AuthProviders by the name of the ConfigMap holding their CA bundle
+	configMapRefIndexKey = ".spec.tls.caBundle.configMapKeyRef.name"
+	// authProviderRefIndexKey indexes Supports by the namespace/name of the AuthProviders their workflows reference
+	authProviderRefIndexKey = ".spec.workflows.authProviderRefs"
 )
 
 // AuthProviderReconciler reconciles a AuthProvider object
 type AuthProviderReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// RecheckInterval is how often the secret and endpoint of every AuthProvider are verified again
+	RecheckInterval time.Duration
+	// ProviderClients is rebuilt from AuthProviders and their Secrets as they change, so that
+	// analyses pick up rotated credentials and updated endpoints without a restart
+	ProviderClients *ai_provider.ClientPool
+	// Recorder records an event on the Supports using an AuthProvider whose client changed
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=authproviders,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=authproviders/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=authproviders/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=supports,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the AuthProvider object against the actual cluster state, and then
-// perform support to make the cluster state reflect the state specified by
-// the user.
+// Reconcile verifies that the Secret referenced by the AuthProvider holds the credentials
+// and that the endpoint answers, and reports the outcome as conditions on the status.
+// The AuthProvider is checked again after RecheckInterval and whenever its Secret or the objects
+// holding its TLS material change, which also rebuilds its client in ProviderClients.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.17.2/pkg/reconcile
 func (r *AuthProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.FromContext(ctx)
+
+	var authProvider argosupportv1alpha2.AuthProvider
+	err := r.Get(ctx, req.NamespacedName, &authProvider)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("AuthProvider not found", "namespace", req.Namespace, "name", req.Name)
+			r.ProviderClients.Remove(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get AuthProvider")
+		return ctrl.Result{}, err
+	}
+
+	secret, err := r.verifySecret(ctx, &authProvider)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if secret != nil {
+		r.verifyEndpoint(ctx, &authProvider, secret)
+	} else {
+		r.ProviderClients.Remove(req.NamespacedName)
+		meta.SetStatusCondition(&authProvider.Status.Conditions, metav1.Condition{
+			Type:    argosupportv1alpha2.AuthProviderConditionUnreachable,
+			Status:  metav1.ConditionUnknown,
+			Reason:  argosupportv1alpha2.AuthProviderReasonNotVerified,
+			Message: "endpoint is not probed until the secret is available",
+		})
+	}
+
+	ready := metav1.Condition{
+		Type:    argosupportv1alpha2.AuthProviderConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  argosupportv1alpha2.AuthProviderReasonVerified,
+		Message: "secret is present and endpoint is reachable",
+	}
+	for _, conditionType := range []string{argosupportv1alpha2.AuthProviderConditionSecretMissing, argosupportv1alpha2.AuthProviderConditionUnreachable} {
+		if c := meta.FindStatusCondition(authProvider.Status.Conditions, conditionType); c != nil && c.Status != metav1.ConditionFalse {
+			ready.Status = metav1.ConditionFalse
+			ready.Reason = c.Reason
+			ready.Message = c.Message
+			break
+		}
+	}
+	meta.SetStatusCondition(&authProvider.Status.Conditions, ready)
+
+	now := metav1.Now()
+	authProvider.Status.LastCheckedTime = &now
+	authProvider.Status.ObservedGeneration = authProvider.Generation
+	if err := r.Status().Update(ctx, &authProvider); err != nil {
+		logger.Error(err, "Failed to update AuthProvider status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: r.recheckInterval()}, nil
+}
+
+// verifySecret sets the SecretMissing condition and returns the Secret when it holds the keys the
+// scheme of the AuthProvider reads its credentials from
+func (r *AuthProviderReconciler) verifySecret(ctx context.Context, authProvider *argosupportv1alpha2.AuthProvider) (*v1.Secret, error) {
+	condition := metav1.Condition{
+		Type:   argosupportv1alpha2.AuthProviderConditionSecretMissing,
+		Status: metav1.ConditionTrue,
+	}
+	defer func() {
+		meta.SetStatusCondition(&authProvider.Status.Conditions, condition)
+	}()
+
+	if authProvider.Spec.SecretRef == nil || authProvider.Spec.SecretRef.Name == "" {
+		condition.Reason = argosupportv1alpha2.AuthProviderReasonSecretRefMissing
+		condition.Message = "spec.secretRef is not set"
+		return nil, nil
+	}
+
+	var secret v1.Secret
+	err := r.Get(ctx, types.NamespacedName{Namespace: authProvider.Namespace, Name: authProvider.Spec.SecretRef.Name}, &secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			condition.Reason = argosupportv1alpha2.AuthProviderReasonSecretNotFound
+			condition.Message = fmt.Sprintf("secret %q not found", authProvider.Spec.SecretRef.Name)
+			return nil, nil
+		}
+		return nil, err
+	}
 
-	// TODO(user): your logic here
+	keys := ai_provider.SecretKeys(&authProvider.Spec)
+	for _, key := range keys {
+		if len(secret.Data[key]) == 0 {
+			condition.Reason = argosupportv1alpha2.AuthProviderReasonSecretKeyNotFound
+			condition.Message = fmt.Sprintf("secret %q has no %q key", secret.Name, key)
+			return nil, nil
+		}
+	}
 
-	return ctrl.Result{}, nil
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = argosupportv1alpha2.AuthProviderReasonSecretFound
+	condition.Message = fmt.Sprintf("secret %q holds the keys %q", secret.Name, keys)
+	return &secret, nil
+}
+
+// verifyEndpoint sets the Unreachable condition from a probe of the AuthProvider endpoint
+func (r *AuthProviderReconciler) verifyEndpoint(ctx context.Context, authProvider *argosupportv1alpha2.AuthProvider, secret *v1.Secret) {
+	condition := metav1.Condition{
+		Type:   argosupportv1alpha2.AuthProviderConditionUnreachable,
+		Status: metav1.ConditionTrue,
+	}
+	defer func() {
+		meta.SetStatusCondition(&authProvider.Status.Conditions, condition)
+	}()
+
+	if _, err := url.ParseRequestURI(authProvider.Spec.Auth.BaseURL); err != nil {
+		condition.Reason = argosupportv1alpha2.AuthProviderReasonInvalidEndpoint
+		condition.Message = fmt.Sprintf("spec.auth.baseUrl is invalid: %v", err)
+		return
+	}
+
+	material, err := ai_provider.GetTLSMaterial(ctx, r.Client, authProvider)
+	if err != nil {
+		r.ProviderClients.Remove(types.NamespacedName{Namespace: authProvider.Namespace, Name: authProvider.Name})
+		condition.Reason = argosupportv1alpha2.AuthProviderReasonInvalidTLS
+		condition.Message = err.Error()
+		return
+	}
+
+	httpClient, changed, err := r.ProviderClients.Update(authProvider, secret, material)
+	if err != nil {
+		condition.Reason = argosupportv1alpha2.AuthProviderReasonInvalidEndpoint
+		condition.Message = err.Error()
+		return
+	}
+	if changed {
+		r.recordClientChanged(ctx, authProvider)
+	}
+	if err := httpClient.Probe(ctx); err != nil {
+		log.FromContext(ctx).Info("AuthProvider endpoint probe failed", "error", err.Error())
+		condition.Reason = argosupportv1alpha2.AuthProviderReasonEndpointUnreachable
+		condition.Message = err.Error()
+		return
+	}
+
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = argosupportv1alpha2.AuthProviderReasonEndpointReachable
+	condition.Message = "endpoint answered the probe"
+}
+
+func (r *AuthProviderReconciler) recheckInterval() time.Duration {
+	if r.RecheckInterval > 0 {
+		return r.RecheckInterval
+	}
+	return defaultRecheckInterval
+}
+
+// recordClientChanged records an event on every Support with a workflow referencing the AuthProvider
+func (r *AuthProviderReconciler) recordClientChanged(ctx context.Context, authProvider *argosupportv1alpha2.AuthProvider) {
+	logger := log.FromContext(ctx)
+	logger.Info("rebuilt the client of the AuthProvider", "generation", authProvider.Generation)
+	if r.Recorder == nil {
+		return
+	}
+
+	supports := &argosupportv1alpha2.SupportList{}
+	key := authProvider.Namespace + "/" + authProvider.Name
+	if err := r.List(ctx, supports, client.MatchingFields{authProviderRefIndexKey: key}); err != nil {
+		logger.Error(err, "failed to list Supports using the AuthProvider")
+		return
+	}
+	for i := range supports.Items {
+		r.Recorder.Eventf(&supports.Items[i], v1.EventTypeNormal, argosupportv1alpha2.SupportReasonAuthProviderChanged,
+			"AuthProvider %s changed, analyses use its updated credentials and endpoint", key)
+	}
+}
+
+// findAuthProvidersForSecret maps a Secret to the AuthProviders in its namespace that reference it
+func (r *AuthProviderReconciler) findAuthProvidersForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	return r.findAuthProviders(ctx, secret, secretRefIndexKey)
+}
+
+// findAuthProvidersForConfigMap maps a ConfigMap to the AuthProviders in its namespace that read their CA bundle from it
+func (r *AuthProviderReconciler) findAuthProvidersForConfigMap(ctx context.Context, configMap client.Object) []reconcile.Request {
+	return r.findAuthProviders(ctx, configMap, configMapRefIndexKey)
+}
+
+// findAuthProviders lists the AuthProviders in the namespace of obj that reference it by name through indexKey
+func (r *AuthProviderReconciler) findAuthProviders(ctx context.Context, obj client.Object, indexKey string) []reconcile.Request {
+	authProviders := &argosupportv1alpha2.AuthProviderList{}
+	err := r.List(ctx, authProviders,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{indexKey: obj.GetName()})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list AuthProviders for object", "namespace", obj.GetNamespace(), "name", obj.GetName(), "index", indexKey)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(authProviders.Items))
+	for _, authProvider := range authProviders.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: authProvider.Namespace, Name: authProvider.Name},
+		})
+	}
+	return requests
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AuthProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &argosupportv1alpha2.AuthProvider{}, secretRefIndexKey, func(obj client.Object) []string {
+		spec := obj.(*argosupportv1alpha2.AuthProvider).Spec
+		var names []string
+		if spec.SecretRef != nil && spec.SecretRef.Name != "" {
+			names = append(names, spec.SecretRef.Name)
+		}
+		if spec.TLS != nil {
+			if spec.TLS.CABundle != nil && spec.TLS.CABundle.SecretKeyRef != nil {
+				names = append(names, spec.TLS.CABundle.SecretKeyRef.Name)
+			}
+			if spec.TLS.ClientCertificate != nil {
+				names = append(names, spec.TLS.ClientCertificate.Name)
+			}
+		}
+		return names
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &argosupportv1alpha2.AuthProvider{}, configMapRefIndexKey, func(obj client.Object) []string {
+		spec := obj.(*argosupportv1alpha2.AuthProvider).Spec
+		if spec.TLS == nil || spec.TLS.CABundle == nil || spec.TLS.CABundle.ConfigMapKeyRef == nil {
+			return nil
+		}
+		return []string{spec.TLS.CABundle.ConfigMapKeyRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &argosupportv1alpha2.Support{}, authProviderRefIndexKey, func(obj client.Object) []string {
+		support := obj.(*argosupportv1alpha2.Support)
+		var keys []string
+		for _, wf := range support.Spec.Workflows {
+			for _, ref := range wf.AuthProviderRefs {
+				namespace := ref.Namespace
+				if namespace == "" {
+					namespace = support.Namespace
+				}
+				keys = append(keys, namespace+"/"+ref.Name)
+			}
+		}
+		return keys
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&argosupportv1alpha1.AuthProvider{}).
+		// status updates do not bump the generation, so the periodic status write does not retrigger the reconcile
+		For(&argosupportv1alpha2.AuthProvider{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// only the metadata of Secrets and ConfigMaps is cached, the referenced ones are read when needed
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findAuthProvidersForSecret), builder.OnlyMetadata).
+		Watches(&v1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.findAuthProvidersForConfigMap), builder.OnlyMetadata).
 		Complete(r)
 }
@@ -18,56 +18,113 @@ package controller
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	argosupportv1alpha1 "github.com/argoproj-labs/argo-support/api/v1alpha1"
+	argosupportv1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
 )
 
 var _ = Describe("AuthProvider Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
+		const secretName = "test-resource-secret"
 
 		ctx := context.Background()
 
 		typeNamespacedName := types.NamespacedName{
 			Name:      resourceName,
-			Namespace: "default", // TODO(user):Modify as needed
+			Namespace: "default",
 		}
-		authprovider := &argosupportv1alpha1.AuthProvider{}
+		secretNamespacedName := types.NamespacedName{
+			Name:      secretName,
+			Namespace: "default",
+		}
+		authprovider := &argosupportv1alpha2.AuthProvider{}
+		var server *httptest.Server
 
 		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/version" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`{"Version":"v2.11.0"}`))
+			}))
+
 			By("creating the custom resource for the Kind AuthProvider")
 			err := k8sClient.Get(ctx, typeNamespacedName, authprovider)
 			if err != nil && errors.IsNotFound(err) {
-				resource := &argosupportv1alpha1.AuthProvider{
+				resource := &argosupportv1alpha2.AuthProvider{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: argosupportv1alpha2.AuthProviderSpec{
+						Type:      argosupportv1alpha2.AuthProviderTypeArgoCD,
+						SecretRef: &v1.LocalObjectReference{Name: secretName},
+						Auth:      argosupportv1alpha2.Auth{BaseURL: server.URL},
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
 		})
 
 		AfterEach(func() {
-			// TODO(user): Cleanup logic after each test, like removing the resource instance.
-			resource := &argosupportv1alpha1.AuthProvider{}
+			server.Close()
+
+			resource := &argosupportv1alpha2.AuthProvider{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance AuthProvider")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+			secret := &v1.Secret{}
+			if err := k8sClient.Get(ctx, secretNamespacedName, secret); err == nil {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			}
 		})
-		It("should successfully reconcile the resource", func() {
-			By("Reconciling the created resource")
+
+		It("should report SecretMissing when the secret does not exist", func() {
+			controllerReconciler := &AuthProviderReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(defaultRecheckInterval))
+
+			resource := &argosupportv1alpha2.AuthProvider{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.LastCheckedTime).NotTo(BeNil())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, argosupportv1alpha2.AuthProviderConditionSecretMissing)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, argosupportv1alpha2.AuthProviderConditionReady)).To(BeTrue())
+		})
+
+		It("should report Ready when the secret exists and the endpoint answers", func() {
+			By("creating the secret referenced by the AuthProvider")
+			Expect(k8sClient.Create(ctx, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      secretName,
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"app.secret": []byte("token")},
+			})).To(Succeed())
+
 			controllerReconciler := &AuthProviderReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
@@ -77,8 +134,55 @@ var _ = Describe("AuthProvider Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			resource := &argosupportv1alpha2.AuthProvider{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, argosupportv1alpha2.AuthProviderConditionSecretMissing)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, argosupportv1alpha2.AuthProviderConditionUnreachable)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, argosupportv1alpha2.AuthProviderConditionReady)).To(BeTrue())
+		})
+
+		It("should rebuild the pooled client when the secret is rotated", func() {
+			By("creating the secret referenced by the AuthProvider")
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      secretName,
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"app.secret": []byte("token")},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+			providerClients := ai_provider.NewClientPool()
+			controllerReconciler := &AuthProviderReconciler{
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				ProviderClients: providerClients,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &argosupportv1alpha2.AuthProvider{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			pooled, err := providerClients.Client(ctx, k8sClient, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pooled.AppSecret).To(Equal("token"))
+
+			By("rotating the secret")
+			secret.Data["app.secret"] = []byte("rotated")
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			rotated, err := providerClients.Client(ctx, k8sClient, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rotated.AppSecret).To(Equal("rotated"))
 		})
 	})
 })
@@ -0,0 +1,62 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RetryBackoff configures the delay between the attempts of a failed workflow run. The delay of
+// the workflow, or requeueDelay when it has none, is the delay after the first attempt.
+type RetryBackoff struct {
+	// Factor multiplies the delay after every further failed attempt
+	Factor float64
+	// Jitter adds up to this fraction of the delay at random
+	Jitter float64
+	// MaxDelay caps the delay between attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryBackoff is used when the reconciler has no backoff configured
+var DefaultRetryBackoff = RetryBackoff{
+	Factor:   2,
+	Jitter:   0.1,
+	MaxDelay: 10 * time.Minute,
+}
+
+// Delay returns the delay before the attempt following the given number of failed attempts
+func (b RetryBackoff) Delay(base time.Duration, attempts int64) time.Duration {
+	if b.Factor < 1 {
+		b.Factor = 1
+	}
+	delay := base
+	for i := int64(1); i < attempts; i++ {
+		if b.MaxDelay > 0 && delay >= b.MaxDelay {
+			break
+		}
+		delay = time.Duration(float64(delay) * b.Factor)
+	}
+	if b.Jitter > 0 {
+		delay = wait.Jitter(delay, b.Jitter)
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay
+}
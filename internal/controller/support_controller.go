@@ -18,42 +18,94 @@ package controller
 
 import (
 	"context"
-	supportv1alpha1 "github.com/argoproj-labs/argo-support/api/v1alpha1"
+	goerrors "errors"
+	"fmt"
+	supportv1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/utils"
 	"github.com/argoproj-labs/argo-support/internal/wf_operations"
-	"github.com/argoproj-labs/argo-support/internal/wf_operations/genai"
+	"github.com/argoproj-labs/argo-support/internal/workerpool"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sort"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 )
 
+// requeueDelay is the delay before retrying a failed attempt of a workflow without a delay
 const requeueDelay = 30 * time.Second
 
+// resubmitDelay is the delay before an attempt the pool did not accept is submitted again
+const resubmitDelay = 5 * time.Second
+
 // SupportReconciler reconciles a Support object
 type SupportReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	DynamicClient dynamic.DynamicClient
 	KubeClient    kubernetes.Interface
+	// DefaultConfigMap is the controller-wide ConfigMap the workflow ConfigMaps are layered over
+	DefaultConfigMap types.NamespacedName
+	// ResultHistoryLimit is the number of results kept in the status of Supports that do not set one,
+	// DefaultResultHistoryLimit when nil
+	ResultHistoryLimit *int32
+	// RetryBackoff spaces the attempts of failed workflow runs, the zero value uses DefaultRetryBackoff
+	RetryBackoff RetryBackoff
+	// StageTimeouts are the deadlines of the stages of an attempt, the zero value uses
+	// wf_operations.DefaultStageTimeouts
+	StageTimeouts wf_operations.StageTimeouts
+	// Pool runs the workflow attempts outside of Reconcile, attempts run inline when it is nil
+	Pool *workerpool.Pool
+	// APIReader reads Supports bypassing the cache when deciding about attempts in flight
+	APIReader client.Reader
+	// ProviderClients shares the clients of AuthProviders between attempts, kept current by the
+	// AuthProvider controller
+	ProviderClients *ai_provider.ClientPool
+
+	// outcomes triggers a reconcile of a Support after an attempt on the pool finished
+	outcomes chan event.GenericEvent
+}
+
+// attemptConditions are the conditions an attempt of a workflow sets
+var attemptConditions = []string{
+	supportv1alpha2.SupportConditionContextCollected,
+	supportv1alpha2.SupportConditionProviderReachable,
+	supportv1alpha2.SupportConditionAnalysisSucceeded,
 }
 
-//+kubebuilder:rbac:groups=support.argoproj.extensions.io,resources=supports,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=support.argoproj.extensions.io,resources=supports/status,verbs=get;update;patch
-//+kubebuilder:rbac:groups=support.argoproj.extensions.io,resources=supports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=supports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=supports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=supports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=argosupport.argoproj.extensions.io,resources=supportresults,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods;pods/log;events,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=argoproj.io,resources=rollouts;analysisruns,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Support object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile only decides which workflow attempts start and records them as running, the attempts
+// themselves run on the worker pool and write their outcome back to the status.
+// Each workflow runs once per run identifier, see runIDOf. A new identifier starts a new run,
+// a failed attempt is retried with a growing delay, see RetryBackoff, until its retry limit is
+// reached or it fails with an error another attempt cannot fix, and spec edits that keep the
+// identifier only mark the existing results stale. A workflow with dependsOn stays pending until
+// the workflows it depends on completed their current run and gives up when one of them does.
+// The cancel annotation and spec.suspend cancel the runs in flight, recording a Cancelled result,
+// and hold back the workflows while they are set. A resumed run goes on with its next attempt.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.17.2/pkg/reconcile
@@ -61,7 +113,7 @@ type SupportReconciler struct {
 func (r *SupportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	var err error
-	var support supportv1alpha1.Support
+	var support supportv1alpha2.Support
 	err = r.Get(ctx, req.NamespacedName, &support, &client.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -76,83 +128,545 @@ func (r *SupportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-
-	if support.ObjectMeta.Generation == support.Status.ObservedGeneration {
-		logger.Info("skipping..no change to spec version with observed version", "support.ObjectMeta.Generation", support.Status.ObservedGeneration)
-		return ctrl.Result{}, err
+	if !support.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
 	}
 
-	for _, wf := range support.Spec.Workflows {
+	pruneWorkflowStatuses(&support)
 
-		if support.Status.Count == wf.RetryLimit {
-			support.Status.Phase = supportv1alpha1.ArgoSupportPhaseError
+	var requeueAfter time.Duration
+	var started []*supportv1alpha2.Workflow
+	cancelReason, cancel := cancelRequested(&support)
+	for i := range support.Spec.Workflows {
+		wf := &support.Spec.Workflows[i]
+		wfStatus := workflowStatus(&support.Status, wf.Name)
+		runID := runIDOf(&support, wf)
+
+		if cancel {
+			r.cancelWorkflow(ctx, &support, wf, cancelReason)
 			continue
 		}
+
+		switch {
+		case wfStatus.Phase == "" || wfStatus.RunID != runID:
+			// first run of the workflow or a re-trigger through spec.runID or initiatedAt
+			logger.Info("starting workflow run", "workflow", wf.Name, "runID", runID)
+			wfStatus.RunID = runID
+			wfStatus.Attempts = 0
+			wfStatus.ResultRefs = nil
+		case wfStatus.Phase == supportv1alpha2.ArgoSupportPhaseCompleted || wfStatus.Phase == supportv1alpha2.ArgoSupportPhaseError:
+			// the run is finished, other spec edits only mark its results stale
+			continue
+		case wfStatus.Phase == supportv1alpha2.ArgoSupportPhaseCancelled:
+			// the cancel request was lifted, the run goes on with its next attempt so the cancelled
+			// attempt, which may still be winding down, keeps its own identity
+			logger.Info("resuming cancelled workflow run", "workflow", wf.Name, "runID", runID, "attempts", wfStatus.Attempts)
+		case wfStatus.Phase == supportv1alpha2.ArgoSupportPhasePending:
+			// the run waits for the workflows it depends on, see below
+		case wfStatus.Phase == supportv1alpha2.ArgoSupportPhaseRunning:
+			interrupted, err := r.attemptInterrupted(ctx, &support, wf)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !interrupted {
+				// the attempt is in progress, its outcome triggers the next reconcile
+				continue
+			}
+			// the lost attempt counts as failed, it is retried after the backoff within the retry limit
+			logger.Info("workflow attempt was interrupted", "workflow", wf.Name, "runID", runID, "attempts", wfStatus.Attempts)
+			r.recordFailure(&support, wf, interruptedError(wfStatus))
+			if wait := retryWait(wfStatus, time.Now()); wait > 0 {
+				requeueAfter = minRequeue(requeueAfter, wait)
+			}
+			continue
+		default:
+			// the previous attempt failed
+			if wait := retryWait(wfStatus, time.Now()); wait > 0 {
+				requeueAfter = minRequeue(requeueAfter, wait)
+				continue
+			}
+			logger.Info("retrying workflow run", "workflow", wf.Name, "runID", runID, "attempts", wfStatus.Attempts)
+		}
+
+		// a run starts once the workflows it depends on completed theirs, retries already passed here
+		if wfStatus.Attempts == 0 {
+			if failed, waiting := dependencyState(&support, wf); failed != "" {
+				wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseError
+				wfStatus.Message = fmt.Sprintf("workflow %s it depends on gave up on its run", failed)
+				continue
+			} else if waiting != "" {
+				wfStatus.Phase = supportv1alpha2.ArgoSupportPhasePending
+				wfStatus.Message = fmt.Sprintf("waiting for workflow %s to complete its run", waiting)
+				continue
+			}
+		}
+
+		wfStatus.Attempts++
+		wfStatus.ObservedGeneration = support.Generation
+		wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseRunning
+		wfStatus.NextRetryTime = nil
+		wfStatus.Message = ""
+		started = append(started, wf)
+	}
+	if len(started) > 0 {
 		now := metav1.Now()
 		support.Status.LastTransitionTime = &now
-		support.Status.Phase = supportv1alpha1.ArgoSupportPhaseRunning
-		support.Status.Count++
-		if err := r.Status().Update(ctx, &support); err != nil {
-			logger.Error(err, "Failed to update Support status to running")
+	}
+	// cancelled runs record a result, which may push older ones out of the status
+	if cancel {
+		if err := r.archiveResults(ctx, &support); err != nil {
 			return ctrl.Result{}, err
 		}
-		// Pass support as an argument to getWfExecutor
-		wfExecutor, err := r.getWfExecutor(ctx, &wf, &support)
-		if wfExecutor != nil {
+	}
 
-			// Pass support as an argument to wfExecutor.Process
-			obj, err := wfExecutor.Process(ctx, &support)
-			if err != nil {
-				logger.Error(err, "Failed to process workflow")
-				support.Status.Phase = supportv1alpha1.ArgoSupportPhaseFailed
-				continue
+	support.Status.Phase = overallPhase(&support.Status)
+	support.Status.ObservedGeneration = support.Generation
+	if len(support.Status.Results) > 0 {
+		if generation, stale := staleGeneration(&support); stale {
+			support.SetCondition(supportv1alpha2.SupportConditionStale, metav1.ConditionTrue, supportv1alpha2.SupportReasonSpecChanged,
+				fmt.Sprintf("results were produced for generation %d, spec is at generation %d", generation, support.Generation))
+		} else {
+			support.SetCondition(supportv1alpha2.SupportConditionStale, metav1.ConditionFalse, supportv1alpha2.SupportReasonUpToDate,
+				"results reflect the current spec")
+		}
+	}
+
+	// Update support object in Kubernetes with latest status
+	if err := r.Status().Update(ctx, &support); err != nil {
+		logger.Error(err, "Failed to update Support status")
+		return ctrl.Result{}, err
+	}
+
+	// the attempts are started once the status records them as running
+	ranInline := false
+	for _, wf := range started {
+		wfStatus, accepted, err := r.startAttempt(ctx, &support, wf)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !accepted {
+			// no outcome event follows an attempt the pool did not take
+			requeueAfter = minRequeue(requeueAfter, resubmitDelay)
+		}
+		if wfStatus != nil {
+			ranInline = true
+			if wait := retryWait(wfStatus, time.Now()); wait > 0 {
+				requeueAfter = minRequeue(requeueAfter, wait)
 			}
+		}
+	}
+	// without the pool no outcome event follows, pending workflows are checked again right away
+	if ranInline && support.Status.Phase == supportv1alpha2.ArgoSupportPhasePending {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
 
-			if obj != nil && len(obj.Status.Results) > 1 {
-				sort.SliceStable(obj.Status.Results, func(i, j int) bool {
-					return (obj.Status.Results[i].FinishedAt.Time).After(obj.Status.Results[j].FinishedAt.Time)
-				})
+// startAttempt runs the attempt of the workflow that the Support records as running, on the pool
+// when the reconciler has one and inline otherwise. It returns the recorded status of the workflow
+// when the attempt ran inline, and reports whether the attempt ran or was queued.
+func (r *SupportReconciler) startAttempt(ctx context.Context, support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) (*supportv1alpha2.WorkflowStatus, bool, error) {
+	logger := log.FromContext(ctx).WithValues("workflow", wf.Name)
+	snapshot := support.DeepCopy()
+	wf = wf.DeepCopy()
+	if r.Pool == nil {
+		recorded, err := r.runAttempt(log.IntoContext(ctx, logger), snapshot, wf)
+		return recorded, true, err
+	}
 
-				if len(obj.Status.Results) > 2 {
-					support.Status.Results = obj.Status.Results[:2]
-				}
+	accepted := r.Pool.Submit(workerpool.Task{
+		Key:       attemptKey(support, workflowStatus(&support.Status, wf.Name)),
+		Namespace: support.Namespace,
+		Run: func(poolCtx context.Context) {
+			poolCtx = log.IntoContext(poolCtx, logger)
+			if _, err := r.runAttempt(poolCtx, snapshot, wf); err != nil {
+				logger.Error(err, "Failed to record the outcome of the workflow attempt")
+			}
+		},
+		// status updates do not trigger a reconcile, the outcome event schedules the next step once
+		// the pool no longer reports the attempt as active
+		OnDone: func(poolCtx context.Context) {
+			select {
+			case r.outcomes <- event.GenericEvent{Object: snapshot}:
+			case <-poolCtx.Done():
+			}
+		},
+	})
+	if !accepted {
+		logger.Info("workflow attempt was not queued, it is picked up again after a delay", "delay", resubmitDelay)
+		return nil, false, nil
+	}
+	return nil, true, nil
+}
+
+// runAttempt makes the attempt of the workflow that the snapshot of the Support records as running
+// and writes its outcome back to the Support. It returns the recorded status of the workflow, or
+// nil when the Support is gone or a newer attempt replaced this one meanwhile.
+func (r *SupportReconciler) runAttempt(ctx context.Context, snapshot *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) (*supportv1alpha2.WorkflowStatus, error) {
+	logger := log.FromContext(ctx)
+	attempt := *workflowStatus(&snapshot.Status, wf.Name)
+
+	result, attemptErr := r.analyze(ctx, snapshot, wf)
+	if attemptErr != nil {
+		logger.Error(attemptErr, "Workflow attempt failed", "runID", attempt.RunID, "attempt", attempt.Attempts)
+	}
+
+	var recorded *supportv1alpha2.WorkflowStatus
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		recorded = nil
+		var latest supportv1alpha2.Support
+		if err := r.reader().Get(ctx, client.ObjectKeyFromObject(snapshot), &latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		wfStatus := workflowStatus(&latest.Status, wf.Name)
+		if wfStatus.RunID != attempt.RunID || wfStatus.Attempts != attempt.Attempts || wfStatus.Phase != supportv1alpha2.ArgoSupportPhaseRunning {
+			logger.Info("dropping the outcome of a replaced or cancelled workflow attempt", "runID", attempt.RunID, "attempt", attempt.Attempts)
+			return nil
+		}
+
+		for _, conditionType := range attemptConditions {
+			if condition := meta.FindStatusCondition(snapshot.Status.Conditions, conditionType); condition != nil {
+				meta.SetStatusCondition(&latest.Status.Conditions, *condition)
 			}
-			now := metav1.Now()
-			support.Status.LastTransitionTime = &now
+		}
+		wfStatus.EffectiveConfig = workflowStatus(&snapshot.Status, wf.Name).EffectiveConfig
+		if attemptErr != nil {
+			r.recordFailure(&latest, wf, attemptErr)
 		} else {
-			support.Status.Phase = supportv1alpha1.ArgoSupportPhaseFailed
-			logger.Error(err, "Failed to get workflow executor")
+			latest.Status.Results = append(latest.Status.Results, *result)
+			wfStatus.ResultRefs = append(wfStatus.ResultRefs, result.Name)
+			if err := r.archiveResults(ctx, &latest); err != nil {
+				return err
+			}
+			wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseCompleted
+			wfStatus.NextRetryTime = nil
+			wfStatus.Message = ""
 		}
+		now := metav1.Now()
+		latest.Status.LastTransitionTime = &now
+		latest.Status.Phase = overallPhase(&latest.Status)
+		if err := r.Status().Update(ctx, &latest); err != nil {
+			return err
+		}
+		recorded = workflowStatus(&latest.Status, wf.Name)
+		return nil
+	})
+	return recorded, err
+}
+
+// analyze makes one attempt of the workflow, recording its progress in the conditions of the Support
+func (r *SupportReconciler) analyze(ctx context.Context, support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) (*supportv1alpha2.Result, error) {
+	config, err := utils.GetEffectiveConfig(ctx, r.Client, r.DefaultConfigMap, wf.ConfigMapRef, support.Namespace)
+	if err != nil {
+		support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonConfigurationError, err.Error())
+		return nil, wf_operations.Permanent(fmt.Errorf("failed to get workflow configuration: %w", err))
 	}
-	if support.Status.Phase == supportv1alpha1.ArgoSupportPhaseCompleted || support.Status.Phase == supportv1alpha1.ArgoSupportPhaseError {
-		support.Status.Count = 0
-		support.Status.ObservedGeneration = support.ObjectMeta.Generation
+	workflowStatus(&support.Status, wf.Name).EffectiveConfig = config
+
+	inputs, err := r.dependencyResults(ctx, support, wf)
+	if err != nil {
+		support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonConfigurationError, err.Error())
+		return nil, err
 	}
 
-	// Update support object in Kubernetes with latest status
-	if err := r.Status().Update(ctx, &support); err != nil {
-		logger.Error(err, "Failed to update Support status to completed")
-		return ctrl.Result{}, err
+	params := wf_operations.ExecutorParams{
+		Dependencies: wf_operations.Dependencies{
+			Client:          r.Client,
+			DynamicClient:   r.DynamicClient,
+			KubeClient:      r.KubeClient,
+			StageTimeouts:   r.stageTimeouts(),
+			ProviderClients: r.ProviderClients,
+		},
+		Workflow: wf,
+		Support:  support,
+		Config:   config,
+		Inputs:   inputs,
 	}
-	// TODO(user): your logic here
-	return ctrl.Result{}, nil
+	wfExecutor, err := wf_operations.NewExecutor(ctx, params)
+	if err != nil {
+		setExecutorCondition(support, err)
+		return nil, err
+	}
+	return wfExecutor.Process(ctx, support)
 }
 
-func (r *SupportReconciler) getWfExecutor(ctx context.Context, wf *supportv1alpha1.Workflow, obj metav1.Object) (wf_operations.Executor, error) {
+// recordFailure records a failed attempt: the run is retried after a backoff unless the error is
+// permanent or the retry limit is reached
+func (r *SupportReconciler) recordFailure(support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow, err error) {
+	wfStatus := workflowStatus(&support.Status, wf.Name)
+	wfStatus.NextRetryTime = nil
+	wfStatus.Message = err.Error()
+	switch {
+	case !wf_operations.IsRetryable(err):
+		wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseError
+		support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonPermanentFailure,
+			fmt.Sprintf("workflow %s gave up on run %q: %v", wf.Name, wfStatus.RunID, err))
+	case wfStatus.Attempts >= maxAttempts(wf):
+		wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseError
+		support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonRetryLimitReached,
+			fmt.Sprintf("workflow %s gave up on run %q after %d attempts: %v", wf.Name, wfStatus.RunID, wfStatus.Attempts, err))
+	default:
+		wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseFailed
+		next := metav1.NewTime(time.Now().Add(r.retryDelay(wf, wfStatus.Attempts)))
+		wfStatus.NextRetryTime = &next
+	}
+}
 
+// cancelRequested reports whether the cancel annotation or spec.suspend is set on the Support and
+// returns the reason recorded for the cancelled runs
+func cancelRequested(support *supportv1alpha2.Support) (string, bool) {
+	if reason, ok := support.GetAnnotations()[supportv1alpha2.AnnotationKeyCancel]; ok {
+		if reason == "" {
+			reason = fmt.Sprintf("cancelled through the %s annotation", supportv1alpha2.AnnotationKeyCancel)
+		}
+		return reason, true
+	}
+	if support.Spec.Suspend {
+		return "the Support is suspended", true
+	}
+	return "", false
+}
+
+// cancelWorkflow stops the running attempt or the pending retry of the workflow and marks its run
+// cancelled, as well as runs waiting for their dependencies, recording a Cancelled result with the
+// reason. Runs that did not start or already finished are left alone.
+func (r *SupportReconciler) cancelWorkflow(ctx context.Context, support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow, reason string) {
+	wfStatus := workflowStatus(&support.Status, wf.Name)
+	switch wfStatus.Phase {
+	case supportv1alpha2.ArgoSupportPhaseRunning, supportv1alpha2.ArgoSupportPhaseFailed, supportv1alpha2.ArgoSupportPhasePending:
+	default:
+		return
+	}
+	if r.Pool != nil {
+		r.Pool.Cancel(attemptKey(support, wfStatus))
+	}
+	log.FromContext(ctx).Info("cancelled workflow run", "workflow", wf.Name, "runID", wfStatus.RunID, "reason", reason)
+
+	now := metav1.Now()
+	result := supportv1alpha2.Result{
+		Name:       wf_operations.ResultName(support, wf.Name, now.Time),
+		Phase:      supportv1alpha2.ArgoSupportPhaseCancelled,
+		FinishedAt: &now,
+		Message:    reason,
+	}
+	support.Status.Results = append(support.Status.Results, result)
+	wfStatus.ResultRefs = append(wfStatus.ResultRefs, result.Name)
+	wfStatus.Phase = supportv1alpha2.ArgoSupportPhaseCancelled
+	wfStatus.NextRetryTime = nil
+	wfStatus.Message = reason
+	support.Status.LastTransitionTime = &now
+	support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonCancelled,
+		fmt.Sprintf("workflow %s run %q was cancelled: %s", wf.Name, wfStatus.RunID, reason))
+}
+
+// attemptInterrupted reports whether the running attempt of the workflow was lost, as the pool
+// does not know it, for instance after a restart of the controller. The Support is read from the
+// API server because the cache may not have seen the outcome of the attempt yet.
+func (r *SupportReconciler) attemptInterrupted(ctx context.Context, support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) (bool, error) {
+	if r.Pool != nil && r.Pool.Active(attemptKey(support, workflowStatus(&support.Status, wf.Name))) {
+		return false, nil
+	}
+	var latest supportv1alpha2.Support
+	if err := r.reader().Get(ctx, client.ObjectKeyFromObject(support), &latest); err != nil {
+		return false, err
+	}
+	cached, current := workflowStatus(&support.Status, wf.Name), workflowStatus(&latest.Status, wf.Name)
+	return current.Phase == supportv1alpha2.ArgoSupportPhaseRunning && current.RunID == cached.RunID && current.Attempts == cached.Attempts, nil
+}
+
+// interruptedError is recorded as the failure of an attempt that was lost
+func interruptedError(wfStatus *supportv1alpha2.WorkflowStatus) error {
+	return fmt.Errorf("attempt %d of run %q was interrupted", wfStatus.Attempts, wfStatus.RunID)
+}
+
+// attemptKey identifies an attempt of a workflow on the pool by its run and attempt number, so a
+// new attempt is not mistaken for one that is still winding down
+func attemptKey(support *supportv1alpha2.Support, wfStatus *supportv1alpha2.WorkflowStatus) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%d", support.Namespace, support.Name, wfStatus.Name, wfStatus.RunID, wfStatus.Attempts)
+}
+
+// stageTimeouts returns the configured stage deadlines or the defaults
+func (r *SupportReconciler) stageTimeouts() wf_operations.StageTimeouts {
+	if r.StageTimeouts == (wf_operations.StageTimeouts{}) {
+		return wf_operations.DefaultStageTimeouts
+	}
+	return r.StageTimeouts
+}
+
+// reader returns the reader bypassing the cache, falling back to the client
+func (r *SupportReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// runIDOf returns the identifier of the requested run of the workflow: spec.runID when set,
+// otherwise the initiatedAt of the workflow
+func runIDOf(support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) string {
+	if support.Spec.RunID != "" {
+		return support.Spec.RunID
+	}
+	if wf.InitiatedAt != nil {
+		return wf.InitiatedAt.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// dependencyState returns the first workflow wf depends on that gave up on its current run, or
+// else the first one that did not complete it yet
+func dependencyState(support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) (failed, waiting string) {
+	for _, name := range wf.DependsOn {
+		dependency := specWorkflow(support, name)
+		if dependency == nil {
+			return name, ""
+		}
+		depStatus := workflowStatus(&support.Status, name)
+		if depStatus.RunID != runIDOf(support, dependency) {
+			// the dependency did not pick up its current run yet
+			if waiting == "" {
+				waiting = name
+			}
+			continue
+		}
+		switch depStatus.Phase {
+		case supportv1alpha2.ArgoSupportPhaseCompleted:
+		case supportv1alpha2.ArgoSupportPhaseError:
+			return name, ""
+		default:
+			if waiting == "" {
+				waiting = name
+			}
+		}
+	}
+	return "", waiting
+}
+
+// specWorkflow returns the named workflow of the spec
+func specWorkflow(support *supportv1alpha2.Support, name string) *supportv1alpha2.Workflow {
+	for i := range support.Spec.Workflows {
+		if support.Spec.Workflows[i].Name == name {
+			return &support.Spec.Workflows[i]
+		}
+	}
+	return nil
+}
+
+// maxAttempts is the number of attempts a run of the workflow gets
+func maxAttempts(wf *supportv1alpha2.Workflow) int64 {
+	if wf.RetryLimit < 1 {
+		return 1
+	}
+	return wf.RetryLimit
+}
+
+// retryDelay returns the delay before the attempt following the given number of failed attempts
+func (r *SupportReconciler) retryDelay(wf *supportv1alpha2.Workflow, attempts int64) time.Duration {
+	base := requeueDelay
+	if wf.Delay != nil {
+		base = wf.Delay.Duration
+	}
+	backoff := r.RetryBackoff
+	if backoff == (RetryBackoff{}) {
+		backoff = DefaultRetryBackoff
+	}
+	return backoff.Delay(base, attempts)
+}
+
+// retryWait returns how long a failed workflow still has to wait for its next attempt
+func retryWait(wfStatus *supportv1alpha2.WorkflowStatus, now time.Time) time.Duration {
+	if wfStatus.Phase != supportv1alpha2.ArgoSupportPhaseFailed || wfStatus.NextRetryTime == nil {
+		return 0
+	}
+	return wfStatus.NextRetryTime.Sub(now)
+}
+
+func minRequeue(current, next time.Duration) time.Duration {
+	if current == 0 || next < current {
+		return next
+	}
+	return current
+}
+
+// overallPhase derives the phase of the Support from the phases of its workflows
+func overallPhase(status *supportv1alpha2.SupportStatus) supportv1alpha2.ArgoSupportPhase {
+	phases := map[supportv1alpha2.ArgoSupportPhase]bool{}
+	for _, wfStatus := range status.Workflows {
+		phases[wfStatus.Phase] = true
+	}
 	switch {
-	case wf.Name == "gen-ai":
-		ops, err := genai.NewGenAIOperations(ctx, r.Client, r.DynamicClient, r.KubeClient, wf, obj.GetNamespace())
-		if err != nil {
-			return nil, err
+	case phases[supportv1alpha2.ArgoSupportPhaseRunning]:
+		return supportv1alpha2.ArgoSupportPhaseRunning
+	case phases[supportv1alpha2.ArgoSupportPhasePending]:
+		return supportv1alpha2.ArgoSupportPhasePending
+	case phases[supportv1alpha2.ArgoSupportPhaseFailed]:
+		return supportv1alpha2.ArgoSupportPhaseFailed
+	case phases[supportv1alpha2.ArgoSupportPhaseError]:
+		return supportv1alpha2.ArgoSupportPhaseError
+	case phases[supportv1alpha2.ArgoSupportPhaseCancelled]:
+		return supportv1alpha2.ArgoSupportPhaseCancelled
+	case phases[supportv1alpha2.ArgoSupportPhaseCompleted]:
+		return supportv1alpha2.ArgoSupportPhaseCompleted
+	default:
+		return status.Phase
+	}
+}
+
+// staleGeneration returns the oldest generation a finished run used when it is older than the spec
+func staleGeneration(support *supportv1alpha2.Support) (int64, bool) {
+	generation := support.Generation
+	for _, wfStatus := range support.Status.Workflows {
+		if wfStatus.ObservedGeneration != 0 && wfStatus.ObservedGeneration < generation {
+			generation = wfStatus.ObservedGeneration
+		}
+	}
+	return generation, generation != support.Generation
+}
+
+// pruneWorkflowStatuses drops the status of workflows that were removed from the spec
+func pruneWorkflowStatuses(support *supportv1alpha2.Support) {
+	statuses := support.Status.Workflows[:0]
+	for _, wfStatus := range support.Status.Workflows {
+		for _, wf := range support.Spec.Workflows {
+			if wf.Name == wfStatus.Name {
+				statuses = append(statuses, wfStatus)
+				break
+			}
 		}
-		return ops, nil
+	}
+	support.Status.Workflows = statuses
+}
+
+// setExecutorCondition records why no executor could be built for the workflow
+func setExecutorCondition(support *supportv1alpha2.Support, err error) {
+	var unknown *wf_operations.UnknownWorkflowError
+	var missing *utils.MissingAuthProvidersError
+	var forbidden *utils.ForbiddenAuthProvidersError
+	var configErr *ai_provider.ConfigurationError
+	switch {
+	case goerrors.As(err, &unknown):
+		support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonUnknownWorkflow, err.Error())
+	case goerrors.As(err, &missing):
+		support.SetCondition(supportv1alpha2.SupportConditionProviderReachable, metav1.ConditionFalse, supportv1alpha2.SupportReasonAuthProviderNotFound, err.Error())
+	case goerrors.As(err, &forbidden), goerrors.As(err, &configErr):
+		support.SetCondition(supportv1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, supportv1alpha2.SupportReasonConfigurationError, err.Error())
 	default:
-		return nil, nil
+		support.SetCondition(supportv1alpha2.SupportConditionProviderReachable, metav1.ConditionFalse, supportv1alpha2.SupportReasonProviderUnavailable, err.Error())
 	}
 }
 
-func (r *SupportReconciler) handleFinalizer(ctx context.Context, ops *supportv1alpha1.Support) error {
+// workflowStatus returns the status entry of the named workflow, adding it when missing
+func workflowStatus(status *supportv1alpha2.SupportStatus, name string) *supportv1alpha2.WorkflowStatus {
+	for i := range status.Workflows {
+		if status.Workflows[i].Name == name {
+			return &status.Workflows[i]
+		}
+	}
+	status.Workflows = append(status.Workflows, supportv1alpha2.WorkflowStatus{Name: name})
+	return &status.Workflows[len(status.Workflows)-1]
+}
+
+func (r *SupportReconciler) handleFinalizer(ctx context.Context, ops *supportv1alpha2.Support) error {
 	// name of our custom finalizer
 
 	// examine DeletionTimestamp to determine if object is under deletion
@@ -160,18 +674,24 @@ func (r *SupportReconciler) handleFinalizer(ctx context.Context, ops *supportv1a
 		// The object is not being deleted, so if it does not have our finalizer,
 		// then lets add the finalizer and update the object. This is equivalent
 		// to registering our finalizer.
-		if !controllerutil.ContainsFinalizer(ops, supportv1alpha1.FinalizerName) {
-			controllerutil.AddFinalizer(ops, supportv1alpha1.FinalizerName)
+		if !controllerutil.ContainsFinalizer(ops, supportv1alpha2.FinalizerName) {
+			controllerutil.AddFinalizer(ops, supportv1alpha2.FinalizerName)
 			if err := r.Update(ctx, ops); err != nil {
 				return err
 			}
 		}
 	} else {
 		// The object is being deleted
-		if controllerutil.ContainsFinalizer(ops, supportv1alpha1.FinalizerName) {
-			// our finalizer is present, so lets handle any external dependency
+		if controllerutil.ContainsFinalizer(ops, supportv1alpha2.FinalizerName) {
+			// our finalizer is present, so lets handle any external dependency:
+			// the attempts in flight are cancelled, their outcome has nowhere to go
+			if r.Pool != nil {
+				for i := range ops.Status.Workflows {
+					r.Pool.Cancel(attemptKey(ops, &ops.Status.Workflows[i]))
+				}
+			}
 			// remove our finalizer from the list and update it.
-			controllerutil.RemoveFinalizer(ops, supportv1alpha1.FinalizerName)
+			controllerutil.RemoveFinalizer(ops, supportv1alpha2.FinalizerName)
 			if err := r.Update(ctx, ops); err != nil {
 				return err
 			}
@@ -181,9 +701,26 @@ func (r *SupportReconciler) handleFinalizer(ctx context.Context, ops *supportv1a
 	return nil
 }
 
+// cancelAnnotationChanged lets setting or removing the cancel annotation through, which does not
+// change the generation
+var cancelAnnotationChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldReason, oldSet := e.ObjectOld.GetAnnotations()[supportv1alpha2.AnnotationKeyCancel]
+		newReason, newSet := e.ObjectNew.GetAnnotations()[supportv1alpha2.AnnotationKeyCancel]
+		return oldSet != newSet || oldReason != newReason
+	},
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *SupportReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&supportv1alpha1.Support{}).
-		Complete(r)
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&supportv1alpha2.Support{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, cancelAnnotationChanged)))
+	if r.Pool != nil {
+		if err := mgr.Add(r.Pool); err != nil {
+			return err
+		}
+		r.outcomes = make(chan event.GenericEvent)
+		bldr = bldr.WatchesRawSource(&source.Channel{Source: r.outcomes}, &handler.EnqueueRequestForObject{})
+	}
+	return bldr.Complete(r)
 }
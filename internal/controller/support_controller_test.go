@@ -17,7 +17,16 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	argosupportv1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
 )
 
 var _ = Describe("Support Controller", func() {
@@ -29,4 +38,263 @@ var _ = Describe("Support Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When archiving results", func() {
+		ctx := context.Background()
+
+		It("should keep the newest results and move the rest to SupportResults", func() {
+			support := &argosupportv1alpha2.Support{
+				ObjectMeta: metav1.ObjectMeta{Name: "history", Namespace: "default"},
+				Spec:       argosupportv1alpha2.SupportSpec{ResultHistoryLimit: ptr.To[int32](1)},
+			}
+			Expect(k8sClient.Create(ctx, support)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, support)).To(Succeed())
+			})
+
+			now := time.Now()
+			for i, name := range []string{"gen-ai-1", "gen-ai-3", "gen-ai-2"} {
+				finishedAt := metav1.NewTime(now.Add(time.Duration([]int{1, 3, 2}[i]) * time.Minute))
+				support.Status.Results = append(support.Status.Results, argosupportv1alpha2.Result{Name: name, FinishedAt: &finishedAt})
+			}
+
+			controllerReconciler := &SupportReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			Expect(controllerReconciler.archiveResults(ctx, support)).To(Succeed())
+			Expect(support.Status.Results).To(HaveLen(1))
+			Expect(support.Status.Results[0].Name).To(Equal("gen-ai-3"))
+
+			var archived argosupportv1alpha2.SupportResultList
+			Expect(k8sClient.List(ctx, &archived, client.InNamespace("default"),
+				client.MatchingLabels{argosupportv1alpha2.LabelKeySupportName: "history"})).To(Succeed())
+			Expect(archived.Items).To(HaveLen(2))
+			for _, item := range archived.Items {
+				Expect(metav1.IsControlledBy(&item, support)).To(BeTrue())
+			}
+		})
+
+		It("should apply a controller-wide limit of 0", func() {
+			support := &argosupportv1alpha2.Support{}
+			Expect((&SupportReconciler{}).resultHistoryLimit(support)).To(Equal(DefaultResultHistoryLimit))
+			Expect((&SupportReconciler{ResultHistoryLimit: ptr.To[int32](0)}).resultHistoryLimit(support)).To(Equal(0))
+
+			support.Spec.ResultHistoryLimit = ptr.To[int32](1)
+			Expect((&SupportReconciler{ResultHistoryLimit: ptr.To[int32](0)}).resultHistoryLimit(support)).To(Equal(1))
+		})
+
+		It("should only accept an existing SupportResult holding the same result", func() {
+			support := &argosupportv1alpha2.Support{
+				ObjectMeta: metav1.ObjectMeta{Name: "archived", Namespace: "default"},
+				Spec:       argosupportv1alpha2.SupportSpec{ResultHistoryLimit: ptr.To[int32](0)},
+			}
+			Expect(k8sClient.Create(ctx, support)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, support)).To(Succeed())
+			})
+			other := &argosupportv1alpha2.SupportResult{
+				ObjectMeta: metav1.ObjectMeta{Name: supportResultName(support.Name, "gen-ai-2"), Namespace: "default"},
+				Spec:       argosupportv1alpha2.SupportResultSpec{SupportName: "archived", Result: argosupportv1alpha2.Result{Name: "gen-ai-2"}},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+			})
+
+			controllerReconciler := &SupportReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			// a retried status update archives the same result twice
+			support.Status.Results = []argosupportv1alpha2.Result{{Name: "gen-ai-1"}}
+			Expect(controllerReconciler.archiveResults(ctx, support)).To(Succeed())
+			support.Status.Results = []argosupportv1alpha2.Result{{Name: "gen-ai-1"}}
+			Expect(controllerReconciler.archiveResults(ctx, support)).To(Succeed())
+
+			// a SupportResult left by a Support of the same name that was deleted holds another run
+			support.Status.Results = []argosupportv1alpha2.Result{{Name: "gen-ai-2"}}
+			Expect(controllerReconciler.archiveResults(ctx, support)).NotTo(Succeed())
+			Expect(support.Status.Results).To(HaveLen(1))
+		})
+	})
+
+	Context("When deciding which run to process", func() {
+		It("should prefer spec.runID over initiatedAt", func() {
+			initiatedAt := metav1.NewTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+			wf := &argosupportv1alpha2.Workflow{Name: "gen-ai", InitiatedAt: &initiatedAt}
+			support := &argosupportv1alpha2.Support{Spec: argosupportv1alpha2.SupportSpec{Workflows: []argosupportv1alpha2.Workflow{*wf}}}
+			Expect(runIDOf(support, wf)).To(Equal("2024-05-01T10:00:00Z"))
+
+			support.Spec.RunID = "retrigger-1"
+			Expect(runIDOf(support, wf)).To(Equal("retrigger-1"))
+		})
+
+		It("should derive the phase of the Support from its workflows", func() {
+			status := &argosupportv1alpha2.SupportStatus{Workflows: []argosupportv1alpha2.WorkflowStatus{
+				{Name: "a", Phase: argosupportv1alpha2.ArgoSupportPhaseCompleted},
+				{Name: "b", Phase: argosupportv1alpha2.ArgoSupportPhaseError},
+			}}
+			Expect(overallPhase(status)).To(Equal(argosupportv1alpha2.ArgoSupportPhaseError))
+
+			status.Workflows[1].Phase = argosupportv1alpha2.ArgoSupportPhaseFailed
+			Expect(overallPhase(status)).To(Equal(argosupportv1alpha2.ArgoSupportPhaseFailed))
+
+			status.Workflows[1].Phase = argosupportv1alpha2.ArgoSupportPhasePending
+			Expect(overallPhase(status)).To(Equal(argosupportv1alpha2.ArgoSupportPhasePending))
+
+			status.Workflows[1].Phase = argosupportv1alpha2.ArgoSupportPhaseCompleted
+			Expect(overallPhase(status)).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCompleted))
+		})
+	})
+	Context("When workflows depend on each other", func() {
+		It("should hold back a workflow until its dependencies completed their current run", func() {
+			support := &argosupportv1alpha2.Support{
+				Spec: argosupportv1alpha2.SupportSpec{RunID: "run-2", Workflows: []argosupportv1alpha2.Workflow{
+					{Name: "gen-ai"},
+					{Name: "notify", DependsOn: []string{"gen-ai"}},
+				}},
+				Status: argosupportv1alpha2.SupportStatus{Workflows: []argosupportv1alpha2.WorkflowStatus{
+					{Name: "gen-ai", RunID: "run-1", Phase: argosupportv1alpha2.ArgoSupportPhaseCompleted},
+				}},
+			}
+			notify := &support.Spec.Workflows[1]
+			failed, waiting := dependencyState(support, notify)
+			Expect(failed).To(BeEmpty())
+			Expect(waiting).To(Equal("gen-ai"))
+
+			support.Status.Workflows[0].RunID = "run-2"
+			failed, waiting = dependencyState(support, notify)
+			Expect(failed).To(BeEmpty())
+			Expect(waiting).To(BeEmpty())
+
+			support.Status.Workflows[0].Phase = argosupportv1alpha2.ArgoSupportPhaseError
+			failed, _ = dependencyState(support, notify)
+			Expect(failed).To(Equal("gen-ai"))
+		})
+
+		It("should hand the latest results of the dependencies to the executor", func() {
+			support := &argosupportv1alpha2.Support{
+				ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: "default"},
+				Spec: argosupportv1alpha2.SupportSpec{Workflows: []argosupportv1alpha2.Workflow{
+					{Name: "gen-ai"},
+					{Name: "notify", DependsOn: []string{"gen-ai"}},
+				}},
+				Status: argosupportv1alpha2.SupportStatus{
+					Results: []argosupportv1alpha2.Result{{Name: "gen-ai-2", Summary: "image pull failure"}},
+					Workflows: []argosupportv1alpha2.WorkflowStatus{
+						{Name: "gen-ai", Phase: argosupportv1alpha2.ArgoSupportPhaseCompleted, ResultRefs: []string{"gen-ai-1", "gen-ai-2"}},
+					},
+				},
+			}
+			reconciler := &SupportReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			inputs, err := reconciler.dependencyResults(context.Background(), support, &support.Spec.Workflows[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inputs).To(HaveKey("gen-ai"))
+			Expect(inputs["gen-ai"].Summary).To(Equal("image pull failure"))
+
+			support.Status.Results = nil
+			_, err = reconciler.dependencyResults(context.Background(), support, &support.Spec.Workflows[1])
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When retrying a failed run", func() {
+		It("should grow the delay with every attempt up to the maximum", func() {
+			backoff := RetryBackoff{Factor: 2, MaxDelay: time.Minute}
+			Expect(backoff.Delay(10*time.Second, 1)).To(Equal(10 * time.Second))
+			Expect(backoff.Delay(10*time.Second, 3)).To(Equal(40 * time.Second))
+			Expect(backoff.Delay(10*time.Second, 10)).To(Equal(time.Minute))
+		})
+
+		It("should wait until the next retry time of a failed workflow", func() {
+			now := time.Now()
+			next := metav1.NewTime(now.Add(time.Minute))
+			wfStatus := &argosupportv1alpha2.WorkflowStatus{Phase: argosupportv1alpha2.ArgoSupportPhaseFailed, NextRetryTime: &next}
+			Expect(retryWait(wfStatus, now)).To(BeNumerically(">", 0))
+			Expect(retryWait(wfStatus, now.Add(2*time.Minute))).To(BeNumerically("<=", 0))
+
+			wfStatus.Phase = argosupportv1alpha2.ArgoSupportPhaseError
+			Expect(retryWait(wfStatus, now)).To(BeZero())
+		})
+
+		It("should count an interrupted attempt against the retry limit", func() {
+			support := &argosupportv1alpha2.Support{
+				Spec: argosupportv1alpha2.SupportSpec{Workflows: []argosupportv1alpha2.Workflow{{Name: "a", RetryLimit: 2}}},
+				Status: argosupportv1alpha2.SupportStatus{Workflows: []argosupportv1alpha2.WorkflowStatus{
+					{Name: "a", Phase: argosupportv1alpha2.ArgoSupportPhaseRunning, RunID: "run", Attempts: 1},
+				}},
+			}
+			reconciler := &SupportReconciler{}
+			wf, wfStatus := &support.Spec.Workflows[0], &support.Status.Workflows[0]
+
+			reconciler.recordFailure(support, wf, interruptedError(wfStatus))
+			Expect(wfStatus.Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseFailed))
+			Expect(retryWait(wfStatus, time.Now())).To(BeNumerically(">", 0))
+
+			wfStatus.Phase = argosupportv1alpha2.ArgoSupportPhaseRunning
+			wfStatus.Attempts = maxAttempts(wf)
+			reconciler.recordFailure(support, wf, interruptedError(wfStatus))
+			Expect(wfStatus.Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseError))
+			Expect(wfStatus.NextRetryTime).To(BeNil())
+		})
+	})
+	Context("When cancelling runs", func() {
+		It("should cancel running and pending runs and leave finished ones", func() {
+			support := &argosupportv1alpha2.Support{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cancel",
+					Namespace:   "default",
+					Annotations: map[string]string{argosupportv1alpha2.AnnotationKeyCancel: "rollout was aborted"},
+				},
+				Spec: argosupportv1alpha2.SupportSpec{Workflows: []argosupportv1alpha2.Workflow{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d", DependsOn: []string{"a"}}}},
+				Status: argosupportv1alpha2.SupportStatus{Workflows: []argosupportv1alpha2.WorkflowStatus{
+					{Name: "a", Phase: argosupportv1alpha2.ArgoSupportPhaseRunning},
+					{Name: "b", Phase: argosupportv1alpha2.ArgoSupportPhaseFailed, NextRetryTime: &metav1.Time{Time: time.Now()}},
+					{Name: "c", Phase: argosupportv1alpha2.ArgoSupportPhaseCompleted},
+					{Name: "d", Phase: argosupportv1alpha2.ArgoSupportPhasePending},
+				}},
+			}
+			reason, cancel := cancelRequested(support)
+			Expect(cancel).To(BeTrue())
+			Expect(reason).To(Equal("rollout was aborted"))
+
+			reconciler := &SupportReconciler{}
+			for i := range support.Spec.Workflows {
+				reconciler.cancelWorkflow(context.Background(), support, &support.Spec.Workflows[i], reason)
+			}
+			Expect(support.Status.Workflows[0].Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCancelled))
+			Expect(support.Status.Workflows[0].Message).To(Equal("rollout was aborted"))
+			Expect(support.Status.Workflows[1].Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCancelled))
+			Expect(support.Status.Workflows[1].NextRetryTime).To(BeNil())
+			Expect(support.Status.Workflows[2].Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCompleted))
+			Expect(support.Status.Workflows[3].Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCancelled))
+			Expect(overallPhase(&support.Status)).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCancelled))
+
+			Expect(support.Status.Results).To(HaveLen(3))
+			for _, result := range support.Status.Results {
+				Expect(result.Phase).To(Equal(argosupportv1alpha2.ArgoSupportPhaseCancelled))
+				Expect(result.Message).To(Equal("rollout was aborted"))
+			}
+			Expect(support.Status.Workflows[0].ResultRefs).To(Equal([]string{support.Status.Results[0].Name}))
+			Expect(support.Status.Workflows[2].ResultRefs).To(BeEmpty())
+		})
+
+		It("should give a resumed run a new attempt on the pool", func() {
+			support := &argosupportv1alpha2.Support{ObjectMeta: metav1.ObjectMeta{Name: "resume", Namespace: "default"}}
+			cancelled := &argosupportv1alpha2.WorkflowStatus{Name: "a", RunID: "run", Attempts: 1, Phase: argosupportv1alpha2.ArgoSupportPhaseCancelled}
+			resumed := cancelled.DeepCopy()
+			resumed.Attempts++
+			Expect(attemptKey(support, resumed)).NotTo(Equal(attemptKey(support, cancelled)))
+		})
+
+		It("should treat spec.suspend as a cancel request", func() {
+			support := &argosupportv1alpha2.Support{Spec: argosupportv1alpha2.SupportSpec{Suspend: true}}
+			_, cancel := cancelRequested(support)
+			Expect(cancel).To(BeTrue())
+
+			support.Spec.Suspend = false
+			_, cancel = cancelRequested(support)
+			Expect(cancel).To(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,163 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	supportv1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultResultHistoryLimit is the number of results kept in the status when neither the Support
+// nor the controller set a limit
+const DefaultResultHistoryLimit = 2
+
+// resultHistoryLimit returns the number of results the status of the Support keeps
+func (r *SupportReconciler) resultHistoryLimit(support *supportv1alpha2.Support) int {
+	if support.Spec.ResultHistoryLimit != nil {
+		return int(*support.Spec.ResultHistoryLimit)
+	}
+	if r.ResultHistoryLimit != nil {
+		return int(*r.ResultHistoryLimit)
+	}
+	return DefaultResultHistoryLimit
+}
+
+// archiveResults orders the results newest first and moves the ones beyond the history limit to
+// SupportResult objects owned by the Support
+func (r *SupportReconciler) archiveResults(ctx context.Context, support *supportv1alpha2.Support) error {
+	logger := log.FromContext(ctx)
+
+	results := support.Status.Results
+	sort.SliceStable(results, func(i, j int) bool {
+		return finishedAt(&results[i]).After(finishedAt(&results[j]).Time)
+	})
+
+	limit := r.resultHistoryLimit(support)
+	if len(results) <= limit {
+		return nil
+	}
+
+	for _, result := range results[limit:] {
+		supportResult := &supportv1alpha2.SupportResult{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      supportResultName(support.Name, result.Name),
+				Namespace: support.Namespace,
+				Labels:    map[string]string{supportv1alpha2.LabelKeySupportName: support.Name},
+			},
+			Spec: supportv1alpha2.SupportResultSpec{
+				SupportName: support.Name,
+				Result:      result,
+			},
+		}
+		if err := controllerutil.SetControllerReference(support, supportResult, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, supportResult); errors.IsAlreadyExists(err) {
+			// an update of the status that conflicted may have archived the result already
+			if err := r.checkArchived(ctx, support, supportResult); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		logger.Info("archived result", "result", result.Name, "supportResult", supportResult.Name)
+	}
+	support.Status.Results = results[:limit]
+	return nil
+}
+
+// checkArchived returns an error unless the existing SupportResult of the same name holds the
+// result of the Support it is about to archive, as names carry the run and attempt of a result
+func (r *SupportReconciler) checkArchived(ctx context.Context, support *supportv1alpha2.Support, supportResult *supportv1alpha2.SupportResult) error {
+	var existing supportv1alpha2.SupportResult
+	if err := r.reader().Get(ctx, client.ObjectKeyFromObject(supportResult), &existing); err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(&existing, support) || existing.Spec.Result.Name != supportResult.Spec.Result.Name {
+		return fmt.Errorf("SupportResult %s already holds result %s of another run", existing.Name, existing.Spec.Result.Name)
+	}
+	return nil
+}
+
+// finishedAt orders results without a finish time last
+func finishedAt(result *supportv1alpha2.Result) metav1.Time {
+	if result.FinishedAt == nil {
+		return metav1.Time{}
+	}
+	return *result.FinishedAt
+}
+
+// supportResultName derives a valid object name for an archived result
+func supportResultName(supportName, resultName string) string {
+	name := strings.ToLower(fmt.Sprintf("%s-%s", supportName, resultName))
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = name[:validation.DNS1123SubdomainMaxLength]
+	}
+	return strings.TrimRight(name, "-.")
+}
+
+// dependencyResults returns the latest result of each workflow wf depends on, from the status of
+// the Support or, once archived, from its SupportResult
+func (r *SupportReconciler) dependencyResults(ctx context.Context, support *supportv1alpha2.Support, wf *supportv1alpha2.Workflow) (map[string]supportv1alpha2.Result, error) {
+	if len(wf.DependsOn) == 0 {
+		return nil, nil
+	}
+	inputs := map[string]supportv1alpha2.Result{}
+	for _, name := range wf.DependsOn {
+		refs := workflowStatus(&support.Status, name).ResultRefs
+		if len(refs) == 0 {
+			continue
+		}
+		resultName := refs[len(refs)-1]
+		if result := findResult(support.Status.Results, resultName); result != nil {
+			inputs[name] = *result
+			continue
+		}
+
+		var supportResult supportv1alpha2.SupportResult
+		key := client.ObjectKey{Namespace: support.Namespace, Name: supportResultName(support.Name, resultName)}
+		if err := r.Get(ctx, key, &supportResult); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, wf_operations.Permanent(fmt.Errorf("result %s of workflow %s is gone", resultName, name))
+			}
+			return nil, err
+		}
+		inputs[name] = supportResult.Spec.Result
+	}
+	return inputs, nil
+}
+
+// findResult returns the named result
+func findResult(results []supportv1alpha2.Result, name string) *supportv1alpha2.Result {
+	for i := range results {
+		if results[i].Name == name {
+			return &results[i]
+		}
+	}
+	return nil
+}
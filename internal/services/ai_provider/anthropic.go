@@ -0,0 +1,147 @@
+package ai_provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// anthropicMessagesEndPoint and anthropicModelsEndPoint are appended to the base URL of anthropic
+	// providers, which excludes the version segment as in the Anthropic client libraries, e.g.
+	// https://api.anthropic.com
+	anthropicMessagesEndPoint = "/v1/messages"
+	anthropicModelsEndPoint   = "/v1/models"
+	// anthropicVersion is the version of the Messages API the requests are written against
+	anthropicVersion = "2023-06-01"
+	// anthropicDefaultMaxTokens is sent when the AuthProvider sets no limit, the API requires one
+	anthropicDefaultMaxTokens = 1024
+)
+
+// MessagesProvider analyzes failures through the Anthropic Messages API
+type MessagesProvider struct {
+	client HttpClient
+}
+
+var _ Provider = &MessagesProvider{}
+
+type messagesTurn struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type messagesRequest struct {
+	Model       string         `json:"model"`
+	System      string         `json:"system,omitempty"`
+	Messages    []messagesTurn `json:"messages"`
+	MaxTokens   int32          `json:"max_tokens"`
+	Temperature *float64       `json:"temperature,omitempty"`
+}
+
+type messagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+type messagesErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// AuthorizationHeader returns the credentials of the scheme of the AuthProvider, by default the API
+// key the Messages API expects in the x-api-key header
+func (p *MessagesProvider) AuthorizationHeader(ctx context.Context) (string, error) {
+	value, err := p.client.AuthorizationHeader(ctx)
+	if err == nil && value == "" {
+		return "", fmt.Errorf("no API key configured for the anthropic provider")
+	}
+	return value, err
+}
+
+// Analyze sends the instructions as system prompt and the context as the user turn and returns
+// the text of the answer
+func (p *MessagesProvider) Analyze(ctx context.Context, authorizationHeader string, request *AnalysisRequest) (*Answer, error) {
+	logger := log.FromContext(ctx)
+	if request.Context == "" {
+		return nil, fmt.Errorf("skipping the messages request due to no context")
+	}
+
+	maxTokens := p.client.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	body, err := json.Marshal(messagesRequest{
+		Model:       p.client.Model,
+		System:      request.Instructions,
+		Messages:    []messagesTurn{{Role: "user", Content: request.Context}},
+		MaxTokens:   maxTokens,
+		Temperature: p.client.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.client.BaseURL+anthropicMessagesEndPoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	p.client.SetAuthorization(req, authorizationHeader)
+	req.Header.Add("anthropic-version", anthropicVersion)
+	for key, value := range p.client.Headers {
+		req.Header.Add(key, value)
+	}
+
+	resp, err := p.client.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		logger.Info("received error status from messages", "status-code", resp.StatusCode, "model", p.client.Model)
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		var errorResponse messagesErrorResponse
+		if json.Unmarshal(data, &errorResponse) == nil && errorResponse.Error.Message != "" {
+			return nil, fmt.Errorf("%s: %s: %w", errorResponse.Error.Type, errorResponse.Error.Message, statusErr)
+		}
+		return nil, statusErr
+	}
+
+	var message messagesResponse
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, &InvalidResponseError{Err: fmt.Errorf("invalid messages response: %w", err)}
+	}
+	switch message.StopReason {
+	case "refusal":
+		return nil, &InvalidResponseError{Err: fmt.Errorf("model %s refused to answer", p.client.Model)}
+	case "max_tokens":
+		logger.Info("message was cut off by the token limit", "model", p.client.Model, "maxTokens", maxTokens)
+	}
+
+	var text strings.Builder
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, &InvalidResponseError{Err: fmt.Errorf("message returned no answer")}
+	}
+	return &Answer{Text: text.String()}, nil
+}
@@ -0,0 +1,138 @@
+package ai_provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// messagesStub answers like the Messages API, replaying the recorded response for the given stop reason
+func messagesStub(t *testing.T, stopReason string, got *messagesRequest, headers *http.Header) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != anthropicMessagesEndPoint {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		*headers = r.Header.Clone()
+		if r.Header.Get("x-api-key") != "sk-ant-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`))
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+  "id": "msg_01",
+  "type": "message",
+  "role": "assistant",
+  "model": "claude-sonnet-4-5",
+  "content": [{"type": "text", "text": "{\"rootCause\": "}, {"type": "text", "text": "\"image tag does not exist\"}"}],
+  "stop_reason": "` + stopReason + `",
+  "usage": {"input_tokens": 42, "output_tokens": 12}
+}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newMessagesProvider(t *testing.T, baseURL string, key string, settings *v1alpha2.LLMSettings) Provider {
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "anthropic"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeAnthropic,
+			Auth:      v1alpha2.Auth{BaseURL: baseURL + "/"},
+			Anthropic: settings,
+		},
+	}
+	client, err := NewHttpClient(authProvider, &v1.Secret{Data: map[string][]byte{AppSecretKey: []byte(key)}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProvider(client)
+}
+
+func TestMessagesProvider(t *testing.T) {
+	var got messagesRequest
+	var headers http.Header
+	server := messagesStub(t, "end_turn", &got, &headers)
+	provider := newMessagesProvider(t, server.URL, "sk-ant-test", &v1alpha2.LLMSettings{Model: "claude-sonnet-4-5", Temperature: "0.3", MaxTokens: 2048})
+
+	header, err := provider.AuthorizationHeader(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := provider.Analyze(context.Background(), header, &AnalysisRequest{Instructions: "answer in JSON", Context: "pods are crash looping"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if answer.Text != `{"rootCause": "image tag does not exist"}` {
+		t.Errorf("expected the text blocks of the message, got %q", answer.Text)
+	}
+	if headers.Get("anthropic-version") != anthropicVersion || headers.Get("Authorization") != "" {
+		t.Errorf("expected the versioned API key headers only, got %v", headers)
+	}
+	if got.Model != "claude-sonnet-4-5" || got.MaxTokens != 2048 || got.Temperature == nil || *got.Temperature != 0.3 {
+		t.Errorf("expected the model settings of the AuthProvider, got %+v", got)
+	}
+	if got.System != "answer in JSON" || len(got.Messages) != 1 || got.Messages[0].Role != "user" || got.Messages[0].Content != "pods are crash looping" {
+		t.Errorf("expected the instructions as system prompt and the context as user turn, got %+v", got)
+	}
+}
+
+func TestMessagesProviderStopReasons(t *testing.T) {
+	var got messagesRequest
+	var headers http.Header
+	server := messagesStub(t, "max_tokens", &got, &headers)
+	provider := newMessagesProvider(t, server.URL, "sk-ant-test", &v1alpha2.LLMSettings{Model: "claude-haiku-4-5"})
+
+	answer, err := provider.Analyze(context.Background(), "sk-ant-test", &AnalysisRequest{Context: "pods are crash looping"})
+	if err != nil || answer.Text == "" {
+		t.Errorf("expected a truncated answer to be returned, got %+v, %v", answer, err)
+	}
+	if got.MaxTokens != anthropicDefaultMaxTokens || got.Temperature != nil || got.System != "" {
+		t.Errorf("expected the default token limit and no system prompt, got %+v", got)
+	}
+
+	refusal := messagesStub(t, "refusal", &got, &headers)
+	provider = newMessagesProvider(t, refusal.URL, "sk-ant-test", &v1alpha2.LLMSettings{Model: "claude-haiku-4-5"})
+	_, err = provider.Analyze(context.Background(), "sk-ant-test", &AnalysisRequest{Context: "pods are crash looping"})
+	var invalid *InvalidResponseError
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected a refusal to be an invalid response, got %v", err)
+	}
+}
+
+func TestMessagesProviderErrors(t *testing.T) {
+	var got messagesRequest
+	var headers http.Header
+	server := messagesStub(t, "end_turn", &got, &headers)
+
+	provider := newMessagesProvider(t, server.URL, "", &v1alpha2.LLMSettings{Model: "claude-haiku-4-5"})
+	if _, err := provider.AuthorizationHeader(context.Background()); err == nil {
+		t.Error("expected an error without an API key")
+	}
+
+	_, err := provider.Analyze(context.Background(), "sk-ant-wrong", &AnalysisRequest{Context: "pods are crash looping"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401 status error, got %v", err)
+	}
+
+	if _, err := NewHttpClient(&v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeAnthropic,
+		Auth: v1alpha2.Auth{BaseURL: server.URL},
+	}}, &v1.Secret{}, nil); err == nil {
+		t.Error("expected an anthropic AuthProvider without a model to be rejected")
+	}
+}
@@ -0,0 +1,296 @@
+package ai_provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Default keys of the Secret holding the material of the basic and oauth2 schemes
+const (
+	BasicUsernameKey      = "username"
+	BasicPasswordKey      = "password"
+	OAuth2ClientIDKey     = "client-id"
+	OAuth2ClientSecretKey = "client-secret"
+)
+
+// Authenticator produces the header authenticating the requests sent to a provider
+type Authenticator interface {
+	// Header is the name of the header carrying the credentials
+	Header() string
+	// Value returns the value of the header, exchanging the credentials first when the scheme
+	// requires it. No header is sent for an empty value.
+	Value(ctx context.Context) (string, error)
+}
+
+// exchanger is implemented by the Authenticators of schemes that trade the material in the Secret
+// for short-lived credentials, which the credential cache keeps until they expire
+type exchanger interface {
+	Authenticator
+	// exchange returns the credentials and the time they expire, zero when unknown
+	exchange(ctx context.Context) (string, time.Time, error)
+}
+
+// staticAuth sends the same header value with every request
+type staticAuth struct {
+	header string
+	value  string
+}
+
+func (a *staticAuth) Header() string {
+	return a.header
+}
+
+func (a *staticAuth) Value(ctx context.Context) (string, error) {
+	return a.value, nil
+}
+
+// bearerAuth sends token as bearer token, or nothing when it is empty
+func bearerAuth(token string) *staticAuth {
+	if token == "" {
+		return &staticAuth{header: "Authorization"}
+	}
+	return &staticAuth{header: "Authorization", value: "Bearer " + token}
+}
+
+// oauth2Auth obtains a bearer token with the OAuth2 client credentials grant
+type oauth2Auth struct {
+	config clientcredentials.Config
+	// transport reaches the token endpoint, http.DefaultTransport when nil
+	transport http.RoundTripper
+}
+
+func (a *oauth2Auth) Header() string {
+	return "Authorization"
+}
+
+func (a *oauth2Auth) Value(ctx context.Context) (string, error) {
+	value, _, err := a.exchange(ctx)
+	return value, err
+}
+
+func (a *oauth2Auth) exchange(ctx context.Context) (string, time.Time, error) {
+	if a.transport != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: a.transport, Timeout: exchangeTimeout})
+	}
+	token, err := a.config.Token(ctx)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to obtain a token from %s: %w", a.config.TokenURL, err)
+	}
+	return token.Type() + " " + token.AccessToken, token.Expiry, nil
+}
+
+// identityServiceAuth exchanges app credentials for an authorization header at the identity service
+type identityServiceAuth struct {
+	endpoint  string
+	appID     string
+	profileID string
+	appSecret string
+	// transport reaches the identity service, http.DefaultTransport when nil
+	transport http.RoundTripper
+}
+
+type IdentityResponse struct {
+	Data struct {
+		IdentitySignInInternalApplicationWithPrivateAuth struct {
+			AuthorizationHeader string `json:"authorizationHeader"`
+		} `json:"identitySignInInternalApplicationWithPrivateAuth"`
+	} `json:"data"`
+}
+
+func (a *identityServiceAuth) Header() string {
+	return "Authorization"
+}
+
+func (a *identityServiceAuth) Value(ctx context.Context) (string, error) {
+	value, _, err := a.exchange(ctx)
+	return value, err
+}
+
+// exchange trades the app credentials for an authorization header. The identity service does not
+// tell how long the header is valid, the zero expiry leaves it to the credential cache.
+func (a *identityServiceAuth) exchange(ctx context.Context) (string, time.Time, error) {
+	headers := make(map[string]string)
+	headers["Authorization"] = fmt.Sprintf("Intuit_IAM_Authentication intuit_appid=%s, intuit_app_secret=%s", a.appID, a.appSecret)
+	headers["Content-Type"] = "application/json"
+
+	requestBody := fmt.Sprintf(`{"query":"mutation identitySignInInternalApplicationWithPrivateAuth($input: Identity_SignInApplicationWithPrivateAuthInput!) { identitySignInInternalApplicationWithPrivateAuth(input: $input) { authorizationHeader }}","variables":{"input":{"profileId":%s}}}`, a.profileID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.endpoint+"/v1/graphql", bytes.NewBufferString(requestBody))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+
+	httpClient := &http.Client{Transport: a.transport, Timeout: exchangeTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", time.Time{}, fmt.Errorf("error getting authorization header: %w", &StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	var identityResponse IdentityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&identityResponse); err != nil {
+		return "", time.Time{}, err
+	}
+
+	authorizationHeader := fmt.Sprintf("%s,intuit_appid=%s,intuit_app_secret=%s", identityResponse.Data.IdentitySignInInternalApplicationWithPrivateAuth.AuthorizationHeader, a.appID, a.appSecret)
+
+	return authorizationHeader, time.Time{}, nil
+}
+
+// defaultAuthenticator is used by clients of AuthProviders without a scheme. It sends the app
+// secret the way each type did before schemes could be configured.
+func (client *HttpClient) defaultAuthenticator() Authenticator {
+	switch {
+	case client.Type == v1alpha2.AuthProviderTypeArgoCD:
+		return &staticAuth{header: "Cookie", value: "argocd.token=" + client.AppSecret + "; Secure; HttpOnly"}
+	case client.Type == v1alpha2.AuthProviderTypeAnthropic:
+		return &staticAuth{header: "x-api-key", value: client.AppSecret}
+	case client.IdentityEndpoint != "":
+		return &identityServiceAuth{endpoint: client.IdentityEndpoint, appID: client.AppID, profileID: client.IdentityJobID, appSecret: client.AppSecret, transport: client.Transport}
+	default:
+		return bearerAuth(client.AppSecret)
+	}
+}
+
+// authenticator returns the Authenticator of the configured scheme or the default of the type
+func (client *HttpClient) authenticator() Authenticator {
+	if client.Auth != nil {
+		return client.Auth
+	}
+	return client.defaultAuthenticator()
+}
+
+// signsInAtIdentityService reports whether the client exchanges its credentials at the identity service,
+// looking through the credential cache wrapping the Authenticator
+func (client *HttpClient) signsInAtIdentityService() bool {
+	auth := client.authenticator()
+	if cached, ok := auth.(*cachedAuth); ok {
+		auth = cached.exchanger
+	}
+	_, identity := auth.(*identityServiceAuth)
+	return identity
+}
+
+// AuthorizationHeader returns the value of the header authenticating the requests sent to the
+// provider, exchanging the credentials first when the scheme requires it
+func (client *HttpClient) AuthorizationHeader(ctx context.Context) (string, error) {
+	return client.authenticator().Value(ctx)
+}
+
+// SetAuthorization adds the value returned by AuthorizationHeader to req under the header of the scheme
+func (client *HttpClient) SetAuthorization(req *http.Request, value string) {
+	if value != "" {
+		req.Header.Set(client.authenticator().Header(), value)
+	}
+}
+
+// SecretKeys returns the keys of the Secret the scheme of the AuthProvider reads its material from
+func SecretKeys(spec *v1alpha2.AuthProviderSpec) []string {
+	auth := spec.Auth
+	switch auth.Scheme {
+	case v1alpha2.AuthSchemeBearer:
+		if auth.Bearer != nil {
+			return []string{orDefault(auth.Bearer.SecretKey, AppSecretKey)}
+		}
+	case v1alpha2.AuthSchemeBasic:
+		if auth.Basic != nil {
+			return []string{orDefault(auth.Basic.UsernameKey, BasicUsernameKey), orDefault(auth.Basic.PasswordKey, BasicPasswordKey)}
+		}
+		return []string{BasicUsernameKey, BasicPasswordKey}
+	case v1alpha2.AuthSchemeAPIKey:
+		if auth.APIKey != nil {
+			return []string{orDefault(auth.APIKey.SecretKey, AppSecretKey)}
+		}
+	case v1alpha2.AuthSchemeOAuth2:
+		if auth.OAuth2 != nil {
+			return []string{orDefault(auth.OAuth2.ClientIDKey, OAuth2ClientIDKey), orDefault(auth.OAuth2.ClientSecretKey, OAuth2ClientSecretKey)}
+		}
+		return []string{OAuth2ClientIDKey, OAuth2ClientSecretKey}
+	case v1alpha2.AuthSchemeIdentityService:
+		if auth.IdentityService != nil {
+			return []string{orDefault(auth.IdentityService.SecretKey, AppSecretKey)}
+		}
+	}
+	return []string{AppSecretKey}
+}
+
+// newAuthenticator builds the Authenticator of the scheme of the AuthProvider from the material in
+// its Secret, nil when it sets no scheme. Schemes exchanging credentials reach their endpoint
+// through transport.
+func newAuthenticator(authProvider *v1alpha2.AuthProvider, secret *v1.Secret, transport http.RoundTripper) (Authenticator, error) {
+	auth := authProvider.Spec.Auth
+	keys := SecretKeys(&authProvider.Spec)
+	material := func(i int) string {
+		return string(secret.Data[keys[i]])
+	}
+
+	switch auth.Scheme {
+	case "":
+		return nil, nil
+	case v1alpha2.AuthSchemeBearer:
+		return bearerAuth(material(0)), nil
+	case v1alpha2.AuthSchemeBasic:
+		credentials := base64.StdEncoding.EncodeToString([]byte(material(0) + ":" + material(1)))
+		return &staticAuth{header: "Authorization", value: "Basic " + credentials}, nil
+	case v1alpha2.AuthSchemeAPIKey:
+		if auth.APIKey == nil || auth.APIKey.Header == "" {
+			return nil, fmt.Errorf("authProvider %s/%s has no spec.auth.apiKey.header", authProvider.Namespace, authProvider.Name)
+		}
+		value := material(0)
+		if auth.APIKey.Prefix != "" && value != "" {
+			value = auth.APIKey.Prefix + " " + value
+		}
+		return &staticAuth{header: auth.APIKey.Header, value: value}, nil
+	case v1alpha2.AuthSchemeOAuth2:
+		if auth.OAuth2 == nil || auth.OAuth2.TokenURL == "" {
+			return nil, fmt.Errorf("authProvider %s/%s has no spec.auth.oauth2.tokenUrl", authProvider.Namespace, authProvider.Name)
+		}
+		return &oauth2Auth{config: clientcredentials.Config{
+			ClientID:     material(0),
+			ClientSecret: material(1),
+			TokenURL:     auth.OAuth2.TokenURL,
+			Scopes:       auth.OAuth2.Scopes,
+		}, transport: transport}, nil
+	case v1alpha2.AuthSchemeIdentityService:
+		identity := &identityServiceAuth{appSecret: material(0), transport: transport}
+		if settings := auth.IdentityService; settings != nil {
+			identity.endpoint, identity.appID, identity.profileID = settings.Endpoint, settings.AppID, settings.ProfileID
+		}
+		if settings := authProvider.Spec.GenAI; settings != nil {
+			identity.endpoint = orDefault(identity.endpoint, settings.IdentityEndpoint)
+			identity.appID = orDefault(identity.appID, settings.AppID)
+			identity.profileID = orDefault(identity.profileID, settings.IdentityJobID)
+		}
+		if identity.endpoint == "" {
+			return nil, fmt.Errorf("authProvider %s/%s has no spec.auth.identityService.endpoint", authProvider.Namespace, authProvider.Name)
+		}
+		return identity, nil
+	default:
+		return nil, fmt.Errorf("authProvider %s/%s has unknown scheme %q", authProvider.Namespace, authProvider.Name, auth.Scheme)
+	}
+}
+
+func orDefault(key, fallback string) string {
+	if key == "" {
+		return fallback
+	}
+	return key
+}
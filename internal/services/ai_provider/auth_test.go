@@ -0,0 +1,134 @@
+package ai_provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// echoAuthorization answers every request and records the headers it was sent with
+func echoAuthorization(t *testing.T, headers *http.Header) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*headers = r.Header.Clone()
+		_, _ = w.Write([]byte(`{"analyses": [{"analysis": "ok"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthSchemes(t *testing.T) {
+	var headers http.Header
+	server := echoAuthorization(t, &headers)
+	secret := &v1.Secret{Data: map[string][]byte{
+		AppSecretKey:       []byte("legacy"),
+		"token":            []byte("static-token"),
+		BasicUsernameKey:   []byte("argo"),
+		BasicPasswordKey:   []byte("s3cret"),
+		"analyzer-api-key": []byte("key-1"),
+	}}
+
+	for _, tc := range []struct {
+		name   string
+		auth   v1alpha2.Auth
+		header string
+		value  string
+	}{
+		{"default", v1alpha2.Auth{}, "Authorization", "Bearer legacy"},
+		{"bearer", v1alpha2.Auth{Scheme: v1alpha2.AuthSchemeBearer, Bearer: &v1alpha2.BearerAuth{SecretKey: "token"}}, "Authorization", "Bearer static-token"},
+		{"basic", v1alpha2.Auth{Scheme: v1alpha2.AuthSchemeBasic}, "Authorization", "Basic YXJnbzpzM2NyZXQ="},
+		{"apiKey", v1alpha2.Auth{Scheme: v1alpha2.AuthSchemeAPIKey, APIKey: &v1alpha2.APIKeyAuth{Header: "X-API-Key", Prefix: "Key", SecretKey: "analyzer-api-key"}}, "X-Api-Key", "Key key-1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.auth.BaseURL = server.URL
+			client, err := NewHttpClient(&v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{Type: v1alpha2.AuthProviderTypeWebhook, Auth: tc.auth}}, secret, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, err := client.AuthorizationHeader(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Analyze(context.Background(), header, &AnalysisRequest{Context: "context"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := headers.Get(tc.header); got != tc.value {
+				t.Errorf("expected %s to be %q, got %q", tc.header, tc.value, got)
+			}
+		})
+	}
+}
+
+func TestAuthSchemeOAuth2(t *testing.T) {
+	var tokenRequests int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "argo-support" || secret != "client-secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "analyze read" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "issued-token", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer tokenServer.Close()
+	var headers http.Header
+	server := echoAuthorization(t, &headers)
+
+	authProvider := &v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeOpenAI,
+		Auth: v1alpha2.Auth{
+			BaseURL: server.URL,
+			Scheme:  v1alpha2.AuthSchemeOAuth2,
+			OAuth2:  &v1alpha2.OAuth2Auth{TokenURL: tokenServer.URL, Scopes: []string{"analyze", "read"}, ClientIDKey: "id"},
+		},
+		OpenAI: &v1alpha2.LLMSettings{Model: "gpt-4o-mini"},
+	}}
+	secret := &v1.Secret{Data: map[string][]byte{"id": []byte("argo-support"), OAuth2ClientSecretKey: []byte("client-secret")}}
+	if keys := SecretKeys(&authProvider.Spec); !reflect.DeepEqual(keys, []string{"id", OAuth2ClientSecretKey}) {
+		t.Errorf("expected the configured and default client keys, got %v", keys)
+	}
+	client, err := NewHttpClient(authProvider, secret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { DefaultCredentialCache.Forget(types.NamespacedName{}) })
+	for i := 0; i < 2; i++ {
+		if err := client.Probe(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected the token to be reused until it expires, got %d token requests", tokenRequests)
+	}
+	if headers.Get("Authorization") != "Bearer issued-token" {
+		t.Errorf("expected the issued token as bearer token, got %q", headers.Get("Authorization"))
+	}
+
+	secret.Data[OAuth2ClientSecretKey] = []byte("wrong")
+	secret.ResourceVersion = "2"
+	client, _ = NewHttpClient(authProvider, secret, nil)
+	if err := client.Probe(context.Background()); err == nil {
+		t.Error("expected the probe to fail when the token endpoint rejects the client")
+	}
+}
+
+func TestAuthSchemeArgoCDDefault(t *testing.T) {
+	client := &HttpClient{Type: v1alpha2.AuthProviderTypeArgoCD, AppSecret: "token"}
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	header, _ := client.AuthorizationHeader(context.Background())
+	client.SetAuthorization(req, header)
+	if req.Header.Get("Cookie") != "argocd.token=token; Secure; HttpOnly" || req.Header.Get("Authorization") != "" {
+		t.Errorf("expected the legacy argocd.token cookie, got %v", req.Header)
+	}
+
+	if keys := SecretKeys(&v1alpha2.AuthProviderSpec{Type: v1alpha2.AuthProviderTypeArgoCD}); !reflect.DeepEqual(keys, []string{AppSecretKey}) {
+		t.Errorf("expected the app.secret key without a scheme, got %v", keys)
+	}
+}
@@ -5,74 +5,65 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/argoproj-labs/argo-support/api/v1alpha1"
-	"github.com/argoproj-labs/argo-support/internal/utils"
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/pkg/plugin"
 	"io"
 	"net/http"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"net/url"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"time"
 )
 
 const (
-	appSecretKey = "app.secret"
+	// AppSecretKey is the key in the AuthProvider Secret that holds the credentials
+	AppSecretKey = "app.secret"
+
+	argocdVersionEndPoint      = "/api/version"
+	openAIModelsEndPoint       = "/models"
+	argocdApplicationsEndPoint = "/api/v1/applications/"
+	probeTimeout               = time.Second * 10
+	// defaultRequestTimeout bounds a request to a provider, including the time it takes to answer
+	defaultRequestTimeout = time.Minute * 30
 )
 
+// StatusError is returned when a provider answers with an error status
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %v", e.Status)
+}
+
 type HttpClient struct {
+	Type             v1alpha2.AuthProviderType
 	BaseURL          string
 	AppID            string
 	AppSecret        string
 	IdentityEndpoint string
 	IdentityJobID    string
 	APIVersion       string
+	AppNamespace     string
+	Headers          map[string]string
+	// Model, Temperature and MaxTokens configure the requests of providers talking to an LLM API directly
+	Model       string
+	Temperature *float64
+	MaxTokens   int32
+	// Timeout overrides the deadline of the llm stage when set
+	Timeout time.Duration
+	// PullModel lets an ollama provider pull a missing model
+	PullModel bool
+	// Auth authenticates the requests with the scheme of the AuthProvider, the default of the type
+	// built from AppSecret and the identity fields when nil
+	Auth Authenticator
+	// Transport carries the requests to the provider with its tls and proxy settings,
+	// http.DefaultTransport when nil
+	Transport http.RoundTripper
 }
 
-type IdentityResponse struct {
-	Data struct {
-		IdentitySignInInternalApplicationWithPrivateAuth struct {
-			AuthorizationHeader string `json:"authorizationHeader"`
-		} `json:"identitySignInInternalApplicationWithPrivateAuth"`
-	} `json:"data"`
-}
-
-func (client *HttpClient) GetAuthorizationHeaderFromIdentityService() (string, error) {
-	headers := make(map[string]string)
-	headers["Authorization"] = fmt.Sprintf("Intuit_IAM_Authentication intuit_appid=%s, intuit_app_secret=%s", client.AppID, client.AppSecret)
-	headers["Content-Type"] = "application/json"
-
-	requestBody := fmt.Sprintf(`{"query":"mutation identitySignInInternalApplicationWithPrivateAuth($input: Identity_SignInApplicationWithPrivateAuthInput!) { identitySignInInternalApplicationWithPrivateAuth(input: $input) { authorizationHeader }}","variables":{"input":{"profileId":%s}}}`, client.IdentityJobID)
-
-	req, err := http.NewRequest("POST", client.IdentityEndpoint+"/v1/graphql", bytes.NewBufferString(requestBody))
-	if err != nil {
-		return "", err
-	}
-
-	for key, value := range headers {
-		req.Header.Add(key, value)
-	}
-
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("error getting authorization header: %v", resp.Status)
-	}
-
-	var identityResponse IdentityResponse
-	if err := json.NewDecoder(resp.Body).Decode(&identityResponse); err != nil {
-		return "", err
-	}
-
-	authorizationHeader := fmt.Sprintf("%s,intuit_appid=%s,intuit_app_secret=%s", identityResponse.Data.IdentitySignInInternalApplicationWithPrivateAuth.AuthorizationHeader, client.AppID, client.AppSecret)
-
-	return authorizationHeader, nil
-}
-
-func (client *HttpClient) PostRequest(ctx context.Context, tokens string, endpointSuffix string) (interface{}, error) {
+// PostRequest sends the tokens to the endpoint with the authorization header returned by AuthorizationHeader
+func (client *HttpClient) PostRequest(ctx context.Context, authorizationHeader string, tokens string, endpointSuffix string) (interface{}, error) {
 	logger := log.FromContext(ctx)
 	if len(tokens) == 0 {
 		return nil, fmt.Errorf("skipping the genai request due to no tokens for genai")
@@ -83,30 +74,22 @@ func (client *HttpClient) PostRequest(ctx context.Context, tokens string, endpoi
 	}
 	body := []byte(tokens)
 
-	authorizationHeader, err := client.GetAuthorizationHeaderFromIdentityService()
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", client.BaseURL+"/"+client.APIVersion+endpointSuffix, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", client.endpointURL(endpointSuffix), bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", authorizationHeader)
+	client.SetAuthorization(req, authorizationHeader)
 	req.Header.Add("Content-Type", "application/json")
-
-	httpClient := &http.Client{
-		Timeout: time.Minute * 5,
-	}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		logger.Error(err, "received response error from genai", "status-code", resp.StatusCode)
-		return nil, err
+	for key, value := range client.Headers {
+		req.Header.Add(key, value)
 	}
 
+	resp, err := client.HTTPClient().Do(req)
 	if err != nil {
+		logger.Error(err, "received response error from genai")
 		return nil, err
 	}
+
 	resDataBytes, err := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 
@@ -116,33 +99,57 @@ func (client *HttpClient) PostRequest(ctx context.Context, tokens string, endpoi
 	var resData interface{}
 	err = json.Unmarshal(resDataBytes, &resData)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("unexpected response status: %v", resp.Status)
+		logger.Info("received error status from genai", "status-code", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return resData, nil
 }
 
-func (client *HttpClient) GetRequest(fullUrl string, params map[string]string) (*Application, error) {
-	req, err := http.NewRequest("GET", fullUrl, nil)
+func (client *HttpClient) endpointURL(endpointSuffix string) string {
+	if client.APIVersion == "" {
+		return client.BaseURL + endpointSuffix
+	}
+	return client.BaseURL + "/" + client.APIVersion + endpointSuffix
+}
+
+// GetApplication fetches the Argo CD Application with the given name
+func (client *HttpClient) GetApplication(ctx context.Context, name string) (*Application, error) {
+	params := map[string]string{}
+	if client.AppNamespace != "" {
+		params["appNamespace"] = client.AppNamespace
+	}
+	return client.GetRequest(ctx, client.BaseURL+argocdApplicationsEndPoint+url.PathEscape(name), params)
+}
+
+func (client *HttpClient) GetRequest(ctx context.Context, fullUrl string, params map[string]string) (*Application, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fullUrl, nil)
 	if err != nil {
 		return nil, err
 	}
+	query := req.URL.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	req.URL.RawQuery = query.Encode()
 
-	// Adding headers as per the curl command
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Cookie", "argocd.token="+client.AppSecret+"; Secure; HttpOnly")
-	httpClient := &http.Client{
-
-		Timeout: time.Minute * 5,
+	authorizationHeader, err := client.AuthorizationHeader(ctx)
+	if err != nil {
+		return nil, err
 	}
-	resp, err := httpClient.Do(req)
+	req.Header.Add("Content-Type", "application/json")
+	client.SetAuthorization(req, authorizationHeader)
+	resp, err := client.HTTPClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned non-OK status: %d %s", resp.StatusCode, resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -158,52 +165,66 @@ func (client *HttpClient) GetRequest(fullUrl string, params map[string]string) (
 	return &app, nil
 }
 
-func GetGenAIClientWithSecret(ctx context.Context, k8sClient client.Client, authProviders *[]v1alpha1.AuthProvider, namespace string) (*HttpClient, error) {
-	logger := log.FromContext(ctx)
-	for _, authProvider := range *authProviders {
-		if authProvider.Name == "genai-auth-provider" {
-			secret, err := utils.GetSecret(ctx, k8sClient, &authProvider)
-			if err != nil {
-				logger.Error(err, "failed to get Secret from AuthProvider", "namespace", namespace, "name", authProvider.Name)
-				return nil, err
-			}
-
-			if secret == nil {
-				return nil, fmt.Errorf("secret is missing")
-			}
-
-			return &HttpClient{
-				BaseURL:          authProvider.Spec.Auth.BaseURL,
-				AppID:            authProvider.Spec.Auth.AppID,
-				IdentityEndpoint: authProvider.Spec.Auth.IdentityEndpoint,
-				IdentityJobID:    authProvider.Spec.Auth.IdentityJobID,
-				APIVersion:       authProvider.Spec.Auth.APIVersion,
-				AppSecret:        string(secret.Data[appSecretKey]),
-			}, nil
-		}
+// Probe checks that the endpoint behind the client is reachable with the configured credentials,
+// which schemes such as oauth2 exchange first. GenAI clients signing in at the identity service
+// are probed by the exchange alone, Argo CD clients through the version endpoint, plugins through their manifest, openai, anthropic
+// and ollama clients through the model list, and any other client by requesting its base URL.
+// Ollama servers that may not pull the model must list it.
+func (client *HttpClient) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	authorizationHeader, err := client.AuthorizationHeader(ctx)
+	if err != nil || client.Type == v1alpha2.AuthProviderTypeGenAI && client.signsInAtIdentityService() {
+		return err
+	}
+
+	probeURL := client.BaseURL
+	switch client.Type {
+	case v1alpha2.AuthProviderTypeArgoCD:
+		probeURL += argocdVersionEndPoint
+	case v1alpha2.AuthProviderTypePlugin:
+		probeURL += plugin.ManifestPath
+	case v1alpha2.AuthProviderTypeOpenAI:
+		probeURL += openAIModelsEndPoint
+	case v1alpha2.AuthProviderTypeAnthropic:
+		probeURL += anthropicModelsEndPoint
+	case v1alpha2.AuthProviderTypeOllama:
+		probeURL += ollamaTagsEndPoint
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", probeURL, nil)
+	if err != nil {
+		return err
+	}
+	client.SetAuthorization(req, authorizationHeader)
+	if client.Type == v1alpha2.AuthProviderTypeAnthropic {
+		req.Header.Add("anthropic-version", anthropicVersion)
+	}
+	for key, value := range client.Headers {
+		req.Header.Add(key, value)
 	}
-	return nil, nil
-}
 
-func GetArgoCDClienWithSecret(ctx context.Context, k8sClient client.Client, authProviders *[]v1alpha1.AuthProvider, namespace string) (*HttpClient, error) {
-	logger := log.FromContext(ctx)
-	for _, authProvider := range *authProviders {
-		if authProvider.Name == "argocd-auth-provider" {
-			secret, err := utils.GetSecret(ctx, k8sClient, &authProvider)
-			if err != nil {
-				logger.Error(err, "failed to get Secret from AuthProvider", "namespace", namespace)
-				return nil, err
-			}
-
-			if secret == nil {
-				return nil, fmt.Errorf("secret is missing")
-			}
-
-			return &HttpClient{
-				BaseURL:   authProvider.Spec.Auth.BaseURL,
-				AppSecret: string(secret.Data[appSecretKey]),
-			}, nil
+	resp, err := client.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// only Argo CD, plugins, openai, anthropic and ollama servers have a well known endpoint,
+	// other servers are reachable as long as they answer
+	wellKnown := probeURL != client.BaseURL
+	if wellKnown && resp.StatusCode != http.StatusOK ||
+		resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("server returned non-OK status: %d %s", resp.StatusCode, resp.Status)
+	}
+	if client.Type == v1alpha2.AuthProviderTypeOllama && !client.PullModel {
+		available, err := hasOllamaModel(resp.Body, client.Model)
+		if err != nil {
+			return err
+		}
+		if !available {
+			return fmt.Errorf("model %q is not available on the server", client.Model)
 		}
 	}
-	return nil, nil
+	return nil
 }
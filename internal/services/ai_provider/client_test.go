@@ -1 +1,41 @@
 package ai_provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostRequestHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &HttpClient{BaseURL: server.URL}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.PostRequest(ctx, "Bearer token", `{"failures":[]}`, "/analyze")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to stop at the deadline, got %v", err)
+	}
+}
+
+func TestGetApplicationStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := &HttpClient{BaseURL: server.URL, AppSecret: "token"}
+	_, err := client.GetApplication(context.Background(), "guestbook")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a 403 status error, got %v", err)
+	}
+}
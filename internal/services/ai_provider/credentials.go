@@ -0,0 +1,171 @@
+package ai_provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"golang.org/x/sync/singleflight"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultCredentialTTL is how long credentials without an expiry are kept
+	defaultCredentialTTL = 30 * time.Minute
+	// maxRefreshWindow is how long before they expire credentials are refreshed at most, shorter
+	// lived credentials are refreshed after nine tenths of their lifetime
+	maxRefreshWindow = time.Minute
+	// exchangeTimeout bounds an exchange shared by concurrent analyses
+	exchangeTimeout = 30 * time.Second
+)
+
+// DefaultCredentialCache is shared by the clients of all AuthProviders
+var DefaultCredentialCache = NewCredentialCache()
+
+// CredentialCache keeps the credentials exchanged for the material in the Secret of an
+// AuthProvider until shortly before they expire. Concurrent analyses needing new credentials
+// wait for a single exchange, and an entry is dropped once the AuthProvider or its Secret changes.
+type CredentialCache struct {
+	mu      sync.Mutex
+	entries map[types.NamespacedName]*credentialEntry
+	group   singleflight.Group
+	now     func() time.Time
+}
+
+type credentialEntry struct {
+	// version is the generation of the AuthProvider and the resourceVersion of the Secret the
+	// credentials were exchanged with
+	version   string
+	value     string
+	refreshAt time.Time
+	expiresAt time.Time
+}
+
+// NewCredentialCache creates an empty CredentialCache
+func NewCredentialCache() *CredentialCache {
+	return &CredentialCache{
+		entries: map[types.NamespacedName]*credentialEntry{},
+		now:     time.Now,
+	}
+}
+
+// Forget drops the credentials of the AuthProvider
+func (c *CredentialCache) Forget(authProvider types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, authProvider)
+}
+
+// Len returns the number of AuthProviders with cached credentials
+func (c *CredentialCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get returns the credentials of the AuthProvider, exchanging new ones once the cached ones are
+// due for a refresh. Cached credentials that have not expired yet are returned when the refresh fails.
+func (c *CredentialCache) get(ctx context.Context, authProvider types.NamespacedName, version string, source exchanger) (string, error) {
+	entry := c.lookup(authProvider, version)
+	if entry != nil && c.now().Before(entry.refreshAt) {
+		return entry.value, nil
+	}
+
+	// the exchange is shared by every caller waiting for it and must not end with the first one
+	results := c.group.DoChan(authProvider.String()+"/"+version, func() (interface{}, error) {
+		// the exchange before may have refreshed the entry since it was looked up
+		if entry := c.lookup(authProvider, version); entry != nil && c.now().Before(entry.refreshAt) {
+			return entry, nil
+		}
+		exchangeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), exchangeTimeout)
+		defer cancel()
+		value, expiresAt, err := source.exchange(exchangeCtx)
+		if err != nil {
+			return nil, err
+		}
+		return c.store(authProvider, version, value, expiresAt), nil
+	})
+	var result singleflight.Result
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if err := result.Err; err != nil {
+		if entry != nil && c.now().Before(entry.expiresAt) {
+			log.FromContext(ctx).Info("failed to refresh credentials, using the cached ones until they expire",
+				"authProvider", authProvider, "expiresAt", entry.expiresAt, "error", err.Error())
+			return entry.value, nil
+		}
+		return "", err
+	}
+	return result.Val.(*credentialEntry).value, nil
+}
+
+// lookup returns the entry of the AuthProvider when it was exchanged with the given version and
+// drops it when the AuthProvider or its Secret changed since
+func (c *CredentialCache) lookup(authProvider types.NamespacedName, version string) *credentialEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[authProvider]
+	if !ok {
+		return nil
+	}
+	if entry.version != version {
+		delete(c.entries, authProvider)
+		return nil
+	}
+	return entry
+}
+
+func (c *CredentialCache) store(authProvider types.NamespacedName, version, value string, expiresAt time.Time) *credentialEntry {
+	now := c.now()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(defaultCredentialTTL)
+	}
+	window := expiresAt.Sub(now) / 10
+	if window > maxRefreshWindow {
+		window = maxRefreshWindow
+	}
+	entry := &credentialEntry{version: version, value: value, refreshAt: expiresAt.Add(-window), expiresAt: expiresAt}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[authProvider] = entry
+	return entry
+}
+
+var (
+	_ exchanger = &oauth2Auth{}
+	_ exchanger = &identityServiceAuth{}
+)
+
+// cachedAuth serves the credentials of an exchanging scheme from the cache
+type cachedAuth struct {
+	exchanger
+	cache        *CredentialCache
+	authProvider types.NamespacedName
+	version      string
+}
+
+func (a *cachedAuth) Value(ctx context.Context) (string, error) {
+	return a.cache.get(ctx, a.authProvider, a.version, a.exchanger)
+}
+
+// withCredentialCache serves the credentials of auth from the cache when its scheme exchanges them
+func withCredentialCache(cache *CredentialCache, auth Authenticator, authProvider *v1alpha2.AuthProvider, secret *v1.Secret) Authenticator {
+	source, ok := auth.(exchanger)
+	if !ok {
+		return auth
+	}
+	return &cachedAuth{
+		exchanger:    source,
+		cache:        cache,
+		authProvider: types.NamespacedName{Namespace: authProvider.Namespace, Name: authProvider.Name},
+		version:      fmt.Sprintf("%d/%s", authProvider.Generation, secret.ResourceVersion),
+	}
+}
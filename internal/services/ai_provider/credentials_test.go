@@ -0,0 +1,157 @@
+package ai_provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// countingExchanger issues numbered credentials valid for ttl
+type countingExchanger struct {
+	staticAuth
+	calls   atomic.Int32
+	ttl     time.Duration
+	now     func() time.Time
+	fail    atomic.Bool
+	release chan struct{}
+}
+
+func (e *countingExchanger) exchange(ctx context.Context) (string, time.Time, error) {
+	if e.release != nil {
+		<-e.release
+	}
+	n := e.calls.Add(1)
+	if e.fail.Load() {
+		return "", time.Time{}, errors.New("identity service unavailable")
+	}
+	return fmt.Sprintf("token-%d", n), e.now().Add(e.ttl), nil
+}
+
+func TestCredentialCacheSharesExchange(t *testing.T) {
+	cache := NewCredentialCache()
+	source := &countingExchanger{ttl: time.Hour, now: time.Now, release: make(chan struct{})}
+	key := types.NamespacedName{Namespace: "tenant-a", Name: "genai"}
+
+	var wg sync.WaitGroup
+	values := make([]string, 20)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = cache.get(context.Background(), key, "1/100", source)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+
+	if calls := source.calls.Load(); calls != 1 {
+		t.Errorf("expected concurrent analyses to share one exchange, got %d", calls)
+	}
+	for _, value := range values {
+		if value != "token-1" {
+			t.Fatalf("expected every analysis to get the exchanged token, got %q", value)
+		}
+	}
+}
+
+func TestCredentialCacheRefresh(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewCredentialCache()
+	cache.now = func() time.Time { return now }
+	source := &countingExchanger{ttl: 10 * time.Minute, now: cache.now}
+	key := types.NamespacedName{Namespace: "tenant-a", Name: "genai"}
+
+	get := func(version string) string {
+		value, err := cache.get(context.Background(), key, version, source)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return value
+	}
+
+	if get("1/100") != "token-1" {
+		t.Fatal("expected the first exchange")
+	}
+	now = now.Add(8 * time.Minute)
+	if value := get("1/100"); value != "token-1" {
+		t.Errorf("expected the cached token before the refresh window, got %q", value)
+	}
+	now = now.Add(90 * time.Second)
+	if value := get("1/100"); value != "token-2" {
+		t.Errorf("expected the token to be refreshed a minute before it expires, got %q", value)
+	}
+
+	source.fail.Store(true)
+	now = now.Add(9*time.Minute + 30*time.Second)
+	if value := get("1/100"); value != "token-2" {
+		t.Errorf("expected the cached token while it is valid and the refresh fails, got %q", value)
+	}
+	now = now.Add(time.Minute)
+	if _, err := cache.get(context.Background(), key, "1/100", source); err == nil {
+		t.Error("expected an error once the cached token expired")
+	}
+
+	source.fail.Store(false)
+	get("1/100")
+	if value := get("1/101"); value == get("1/100") {
+		t.Errorf("expected a changed Secret to drop the cached token, got %q", value)
+	}
+	cache.Forget(key)
+	if cache.Len() != 0 {
+		t.Errorf("expected the entry to be forgotten, got %d entries", cache.Len())
+	}
+}
+
+func TestCredentialCacheIdentityService(t *testing.T) {
+	var exchanges atomic.Int32
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges.Add(1)
+		_, _ = w.Write([]byte(`{"data": {"identitySignInInternalApplicationWithPrivateAuth": {"authorizationHeader": "Header token"}}}`))
+	}))
+	defer identity.Close()
+
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "genai", Generation: 1},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:  v1alpha2.AuthProviderTypeGenAI,
+			Auth:  v1alpha2.Auth{BaseURL: "https://genai.example.com"},
+			GenAI: &v1alpha2.GenAISettings{AppID: "app", IdentityEndpoint: identity.URL, IdentityJobID: "1"},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "100"},
+		Data:       map[string][]byte{AppSecretKey: []byte("secret")},
+	}
+	t.Cleanup(func() { DefaultCredentialCache.Forget(types.NamespacedName{Namespace: "tenant-a", Name: "genai"}) })
+
+	for i := 0; i < 3; i++ {
+		client, err := NewHttpClient(authProvider, secret, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.AuthorizationHeader(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if exchanges.Load() != 1 {
+		t.Errorf("expected the clients of an AuthProvider to share the header, got %d exchanges", exchanges.Load())
+	}
+
+	secret.ResourceVersion = "101"
+	client, _ := NewHttpClient(authProvider, secret, nil)
+	if _, err := client.AuthorizationHeader(context.Background()); err != nil || exchanges.Load() != 2 {
+		t.Errorf("expected a rotated Secret to be exchanged again, got %d exchanges, %v", exchanges.Load(), err)
+	}
+}
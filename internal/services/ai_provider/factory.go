@@ -0,0 +1,207 @@
+package ai_provider
+
+import (
+	"fmt"
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"strconv"
+	"strings"
+)
+
+// ClientFactory builds the client for an AuthProvider from the credentials in its Secret
+type ClientFactory func(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error)
+
+var clientFactories = map[v1alpha2.AuthProviderType]ClientFactory{
+	v1alpha2.AuthProviderTypeGenAI:     newGenAIClient,
+	v1alpha2.AuthProviderTypeArgoCD:    newArgoCDClient,
+	v1alpha2.AuthProviderTypeWebhook:   newWebhookClient,
+	v1alpha2.AuthProviderTypePlugin:    newPluginClient,
+	v1alpha2.AuthProviderTypeOpenAI:    newOpenAIClient,
+	v1alpha2.AuthProviderTypeAnthropic: newAnthropicClient,
+	v1alpha2.AuthProviderTypeOllama:    newOllamaClient,
+}
+
+// RegisterClientFactory makes factory responsible for AuthProviders of the given type.
+// It is meant to be called from init functions of the packages adding a backend.
+func RegisterClientFactory(providerType v1alpha2.AuthProviderType, factory ClientFactory) {
+	clientFactories[providerType] = factory
+}
+
+// ConfigurationError is returned when the settings of an AuthProvider do not describe a usable
+// client, which only a change of the AuthProvider or the objects it references can fix
+type ConfigurationError struct {
+	Err error
+}
+
+func (e *ConfigurationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigurationError) Unwrap() error {
+	return e.Err
+}
+
+// NewHttpClient builds the client described by the AuthProvider with the credentials from its Secret.
+// Its transport applies the tls and proxy settings with the certificates read by GetTLSMaterial,
+// which may be nil when the AuthProvider references none. Failures are ConfigurationErrors.
+func NewHttpClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret, material *TLSMaterial) (*HttpClient, error) {
+	client, err := buildHttpClient(authProvider, secret, material)
+	if err != nil {
+		return nil, &ConfigurationError{Err: err}
+	}
+	return client, nil
+}
+
+func buildHttpClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret, material *TLSMaterial) (*HttpClient, error) {
+	if authProvider.Spec.Type == "" {
+		return nil, fmt.Errorf("authProvider %s/%s has no spec.type", authProvider.Namespace, authProvider.Name)
+	}
+	factory, ok := clientFactories[authProvider.Spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("no client available for authProvider type %q", authProvider.Spec.Type)
+	}
+	if authProvider.Spec.Auth.BaseURL == "" {
+		return nil, fmt.Errorf("authProvider %s/%s has no spec.auth.baseUrl", authProvider.Namespace, authProvider.Name)
+	}
+	if material == nil && referencesTLSMaterial(&authProvider.Spec) {
+		return nil, fmt.Errorf("authProvider %s/%s references TLS material that was not read", authProvider.Namespace, authProvider.Name)
+	}
+	transport, err := NewTransport(&authProvider.Spec, material)
+	if err != nil {
+		return nil, fmt.Errorf("authProvider %s/%s: %w", authProvider.Namespace, authProvider.Name, err)
+	}
+	client, err := factory(authProvider, secret)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = transport
+	transport.ResponseHeaderTimeout = client.requestTimeout()
+	if client.Auth == nil {
+		if client.Auth, err = newAuthenticator(authProvider, secret, transport); err != nil {
+			return nil, err
+		}
+	}
+	if client.Auth == nil {
+		client.Auth = client.defaultAuthenticator()
+	}
+	client.Auth = withCredentialCache(DefaultCredentialCache, client.Auth, authProvider, secret)
+	return client, nil
+}
+
+func newGenAIClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	settings := authProvider.Spec.GenAI
+	if settings == nil {
+		settings = &v1alpha2.GenAISettings{}
+	}
+	return &HttpClient{
+		Type:             v1alpha2.AuthProviderTypeGenAI,
+		BaseURL:          authProvider.Spec.Auth.BaseURL,
+		AppID:            settings.AppID,
+		IdentityEndpoint: settings.IdentityEndpoint,
+		IdentityJobID:    settings.IdentityJobID,
+		APIVersion:       settings.APIVersion,
+		AppSecret:        string(secret.Data[AppSecretKey]),
+	}, nil
+}
+
+func newArgoCDClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	client := &HttpClient{
+		Type:      v1alpha2.AuthProviderTypeArgoCD,
+		BaseURL:   authProvider.Spec.Auth.BaseURL,
+		AppSecret: string(secret.Data[AppSecretKey]),
+	}
+	if authProvider.Spec.ArgoCD != nil {
+		client.AppNamespace = authProvider.Spec.ArgoCD.AppNamespace
+	}
+	return client, nil
+}
+
+func newWebhookClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	client := &HttpClient{
+		Type:      v1alpha2.AuthProviderTypeWebhook,
+		BaseURL:   authProvider.Spec.Auth.BaseURL,
+		AppSecret: string(secret.Data[AppSecretKey]),
+	}
+	if authProvider.Spec.Webhook != nil {
+		client.APIVersion = authProvider.Spec.Webhook.APIVersion
+		client.Headers = authProvider.Spec.Webhook.Headers
+	}
+	return client, nil
+}
+
+func newPluginClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	client := &HttpClient{
+		Type:      v1alpha2.AuthProviderTypePlugin,
+		BaseURL:   authProvider.Spec.Auth.BaseURL,
+		AppSecret: string(secret.Data[AppSecretKey]),
+	}
+	if authProvider.Spec.Plugin != nil {
+		client.Headers = authProvider.Spec.Plugin.Headers
+	}
+	return client, nil
+}
+
+func newOpenAIClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	client := &HttpClient{
+		Type:      v1alpha2.AuthProviderTypeOpenAI,
+		BaseURL:   strings.TrimSuffix(authProvider.Spec.Auth.BaseURL, "/"),
+		AppSecret: string(secret.Data[AppSecretKey]),
+	}
+	if err := applyLLMSettings(client, authProvider, authProvider.Spec.OpenAI); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func newAnthropicClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	client := &HttpClient{
+		Type:      v1alpha2.AuthProviderTypeAnthropic,
+		BaseURL:   strings.TrimSuffix(authProvider.Spec.Auth.BaseURL, "/"),
+		AppSecret: string(secret.Data[AppSecretKey]),
+	}
+	if err := applyLLMSettings(client, authProvider, authProvider.Spec.Anthropic); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func newOllamaClient(authProvider *v1alpha2.AuthProvider, secret *v1.Secret) (*HttpClient, error) {
+	client := &HttpClient{
+		Type:      v1alpha2.AuthProviderTypeOllama,
+		BaseURL:   strings.TrimSuffix(authProvider.Spec.Auth.BaseURL, "/"),
+		AppSecret: string(secret.Data[AppSecretKey]),
+	}
+	var settings *v1alpha2.LLMSettings
+	if authProvider.Spec.Ollama != nil {
+		settings = &authProvider.Spec.Ollama.LLMSettings
+		client.PullModel = authProvider.Spec.Ollama.PullModel
+	}
+	if err := applyLLMSettings(client, authProvider, settings); err != nil {
+		return nil, err
+	}
+	if client.Timeout == 0 {
+		client.Timeout = ollamaDefaultTimeout
+	}
+	return client, nil
+}
+
+// applyLLMSettings copies the model settings of the AuthProvider to the client
+func applyLLMSettings(client *HttpClient, authProvider *v1alpha2.AuthProvider, settings *v1alpha2.LLMSettings) error {
+	if settings == nil || settings.Model == "" {
+		return fmt.Errorf("authProvider %s/%s has no spec.%s.model", authProvider.Namespace, authProvider.Name, authProvider.Spec.Type)
+	}
+	client.Model = settings.Model
+	client.MaxTokens = settings.MaxTokens
+	client.Headers = settings.Headers
+	if settings.Timeout != nil {
+		client.Timeout = settings.Timeout.Duration
+	}
+	if settings.Temperature != "" {
+		temperature, err := strconv.ParseFloat(settings.Temperature, 64)
+		if err != nil {
+			return fmt.Errorf("authProvider %s/%s has an invalid temperature: %w", authProvider.Namespace, authProvider.Name, err)
+		}
+		client.Temperature = &temperature
+	}
+	return nil
+}
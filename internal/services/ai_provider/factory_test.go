@@ -0,0 +1,171 @@
+package ai_provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/pkg/plugin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewHttpClient(t *testing.T) {
+	secret := &v1.Secret{Data: map[string][]byte{AppSecretKey: []byte("secret")}}
+
+	genAI := &v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type:  v1alpha2.AuthProviderTypeGenAI,
+		Auth:  v1alpha2.Auth{BaseURL: "https://genai"},
+		GenAI: &v1alpha2.GenAISettings{AppID: "typed-app", APIVersion: "v1"},
+	}}
+	client, err := NewHttpClient(genAI, secret, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.AppID != "typed-app" || client.APIVersion != "v1" || client.AppSecret != "secret" {
+		t.Errorf("expected settings from spec.genai, got %+v", client)
+	}
+
+	untyped := &v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{Auth: v1alpha2.Auth{BaseURL: "https://genai"}}}
+	if _, err := NewHttpClient(untyped, secret, nil); err == nil {
+		t.Error("expected an error for an AuthProvider without type")
+	}
+}
+
+func TestClientForRole(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "argocd"},
+		Data:       map[string][]byte{AppSecretKey: []byte("token")},
+	}).Build()
+
+	authProviders := []v1alpha2.AuthProvider{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "any-name"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeArgoCD,
+			SecretRef: &v1.LocalObjectReference{Name: "argocd"},
+			Auth:      v1alpha2.Auth{BaseURL: "https://argocd"},
+			ArgoCD:    &v1alpha2.ArgoCDSettings{AppNamespace: "apps"},
+		},
+	}}
+
+	pool := NewClientPool()
+	client, err := pool.ClientForRole(context.Background(), k8sClient, &authProviders, v1alpha2.ProviderRoleGitOps, "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.Type != v1alpha2.AuthProviderTypeArgoCD || client.AppSecret != "token" || client.AppNamespace != "apps" {
+		t.Errorf("unexpected client %+v", client)
+	}
+
+	if pooled, _ := pool.ClientForRole(context.Background(), k8sClient, &authProviders, v1alpha2.ProviderRoleGitOps, "default"); pooled != client {
+		t.Error("expected the pooled client to be shared")
+	}
+
+	// a rotated Secret does not change the generation of the AuthProvider
+	var secret v1.Secret
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "argocd"}, &secret); err != nil {
+		t.Fatal(err)
+	}
+	secret.Data[AppSecretKey] = []byte("rotated")
+	if err := k8sClient.Update(context.Background(), &secret); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := pool.ClientForRole(context.Background(), k8sClient, &authProviders, v1alpha2.ProviderRoleGitOps, "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rotated == client || rotated.AppSecret != "rotated" {
+		t.Errorf("expected a client with the rotated secret, got %+v", rotated)
+	}
+
+	if _, err := pool.ClientForRole(context.Background(), k8sClient, &authProviders, v1alpha2.ProviderRoleLLM, "default"); err == nil {
+		t.Error("expected an error when no AuthProvider fills the role")
+	}
+}
+
+func TestProbeArgoCD(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != argocdVersionEndPoint {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if cookie, err := r.Cookie("argocd.token"); err != nil || cookie.Value != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Version":"v2.11.0"}`))
+	}))
+	defer server.Close()
+
+	client := &HttpClient{Type: v1alpha2.AuthProviderTypeArgoCD, BaseURL: server.URL, AppSecret: "token"}
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	client.AppSecret = "wrong"
+	if err := client.Probe(context.Background()); err == nil {
+		t.Error("expected an error for rejected credentials")
+	}
+}
+
+func TestProbeGenAIIdentityService(t *testing.T) {
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"identitySignInInternalApplicationWithPrivateAuth": {"authorizationHeader": "Header token"}}}`))
+	}))
+	defer identity.Close()
+	var requests atomic.Int32
+	genAI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer genAI.Close()
+
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "probed-genai"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:  v1alpha2.AuthProviderTypeGenAI,
+			Auth:  v1alpha2.Auth{BaseURL: genAI.URL},
+			GenAI: &v1alpha2.GenAISettings{AppID: "app", IdentityEndpoint: identity.URL, IdentityJobID: "1"},
+		},
+	}
+	t.Cleanup(func() {
+		DefaultCredentialCache.Forget(types.NamespacedName{Namespace: "default", Name: "probed-genai"})
+	})
+	client, err := NewHttpClient(authProvider, &v1.Secret{Data: map[string][]byte{AppSecretKey: []byte("secret")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Probe(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("expected a genai provider signing in at the identity service to be probed by the exchange alone, got %d requests", requests.Load())
+	}
+}
+
+func TestProbePlugin(t *testing.T) {
+	server := httptest.NewServer(&plugin.Handler{
+		Manifest: plugin.Manifest{Name: "stand-in", ProtocolVersions: plugin.SupportedVersions},
+		Token:    "token",
+	})
+	defer server.Close()
+
+	client := &HttpClient{Type: v1alpha2.AuthProviderTypePlugin, BaseURL: server.URL, AppSecret: "token"}
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	client.AppSecret = "wrong"
+	if err := client.Probe(context.Background()); err == nil {
+		t.Error("expected an error for a rejected token")
+	}
+}
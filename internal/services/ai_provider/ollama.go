@@ -0,0 +1,186 @@
+package ai_provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	ollamaChatEndPoint = "/api/chat"
+	ollamaShowEndPoint = "/api/show"
+	ollamaPullEndPoint = "/api/pull"
+	ollamaTagsEndPoint = "/api/tags"
+	// ollamaDefaultTimeout is the deadline of an analysis by a local model when the AuthProvider
+	// sets none, local models answer slower than hosted ones and may have to be pulled first
+	ollamaDefaultTimeout = 15 * time.Minute
+)
+
+// OllamaProvider analyzes failures through the chat API of an Ollama server running in the
+// cluster, so that the collected context never leaves it
+type OllamaProvider struct {
+	client HttpClient
+}
+
+var _ Provider = &OllamaProvider{}
+
+type ollamaModelRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int32    `json:"num_predict,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []chatMessage  `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  *ollamaOptions `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message    chatMessage `json:"message"`
+	Done       bool        `json:"done"`
+	DoneReason string      `json:"done_reason"`
+}
+
+type ollamaPullResponse struct {
+	Status string `json:"status"`
+}
+
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// AuthorizationHeader returns the credentials of the scheme of the AuthProvider for servers behind
+// an authenticating proxy, servers without authentication get no header when the key is empty
+func (p *OllamaProvider) AuthorizationHeader(ctx context.Context) (string, error) {
+	return p.client.AuthorizationHeader(ctx)
+}
+
+// Analyze makes sure the server has the model, pulling it when the AuthProvider allows to, and
+// returns the answer to the instructions as system message and the context as user message
+func (p *OllamaProvider) Analyze(ctx context.Context, authorizationHeader string, request *AnalysisRequest) (*Answer, error) {
+	logger := log.FromContext(ctx)
+	if request.Context == "" {
+		return nil, fmt.Errorf("skipping the chat request due to no context")
+	}
+
+	if err := p.ensureModel(ctx, authorizationHeader); err != nil {
+		return nil, err
+	}
+
+	chatRequest := ollamaChatRequest{Model: p.client.Model}
+	if p.client.Temperature != nil || p.client.MaxTokens != 0 {
+		chatRequest.Options = &ollamaOptions{Temperature: p.client.Temperature, NumPredict: p.client.MaxTokens}
+	}
+	if request.Instructions != "" {
+		chatRequest.Messages = append(chatRequest.Messages, chatMessage{Role: "system", Content: request.Instructions})
+	}
+	chatRequest.Messages = append(chatRequest.Messages, chatMessage{Role: "user", Content: request.Context})
+
+	var chat ollamaChatResponse
+	if err := p.post(ctx, authorizationHeader, ollamaChatEndPoint, &chatRequest, &chat); err != nil {
+		return nil, err
+	}
+	if !chat.Done || chat.Message.Content == "" {
+		return nil, &InvalidResponseError{Err: fmt.Errorf("chat returned no answer")}
+	}
+	if chat.DoneReason == "length" {
+		logger.Info("chat was cut off by the token limit", "model", p.client.Model, "maxTokens", p.client.MaxTokens)
+	}
+	return &Answer{Text: chat.Message.Content}, nil
+}
+
+// ensureModel checks that the server has the model and pulls it when it is missing and the
+// AuthProvider allows to
+func (p *OllamaProvider) ensureModel(ctx context.Context, authorizationHeader string) error {
+	logger := log.FromContext(ctx)
+
+	err := p.post(ctx, authorizationHeader, ollamaShowEndPoint, &ollamaModelRequest{Model: p.client.Model}, nil)
+	var statusErr *StatusError
+	if err == nil || !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		return err
+	}
+	if !p.client.PullModel {
+		return fmt.Errorf("model %q is not available on %s, pull it or set spec.ollama.pullModel: %w", p.client.Model, p.client.BaseURL, err)
+	}
+
+	logger.Info("pulling model", "model", p.client.Model, "server", p.client.BaseURL)
+	var pull ollamaPullResponse
+	if err := p.post(ctx, authorizationHeader, ollamaPullEndPoint, &ollamaModelRequest{Model: p.client.Model}, &pull); err != nil {
+		return fmt.Errorf("failed to pull model %q: %w", p.client.Model, err)
+	}
+	if pull.Status != "success" {
+		return fmt.Errorf("failed to pull model %q: %s", p.client.Model, pull.Status)
+	}
+	return nil
+}
+
+// post sends body to the endpoint of the server and decodes the answer into out unless it is nil
+func (p *OllamaProvider) post(ctx context.Context, authorizationHeader, endpoint string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.client.BaseURL+endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	p.client.SetAuthorization(req, authorizationHeader)
+	for key, value := range p.client.Headers {
+		req.Header.Add(key, value)
+	}
+
+	resp, err := p.client.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		log.FromContext(ctx).Info("received error status from ollama", "status-code", resp.StatusCode, "endpoint", endpoint, "model", p.client.Model)
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &InvalidResponseError{Err: fmt.Errorf("invalid response from %s: %w", endpoint, err)}
+	}
+	return nil
+}
+
+// hasOllamaModel reports whether the model list answered by the tags endpoint holds the model,
+// a model without a tag names its latest tag
+func hasOllamaModel(body io.Reader, model string) (bool, error) {
+	var tags ollamaTagsResponse
+	if err := json.NewDecoder(body).Decode(&tags); err != nil {
+		return false, fmt.Errorf("invalid model list: %w", err)
+	}
+	if !strings.Contains(model, ":") {
+		model += ":latest"
+	}
+	for _, m := range tags.Models {
+		if m.Name == model {
+			return true, nil
+		}
+	}
+	return false, nil
+}
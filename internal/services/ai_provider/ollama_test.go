@@ -0,0 +1,157 @@
+package ai_provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ollamaStub serves the chat, model and pull endpoints of an Ollama server holding the given models
+type ollamaStub struct {
+	mu     sync.Mutex
+	models map[string]bool
+	pulls  int
+	chat   ollamaChatRequest
+}
+
+func (s *ollamaStub) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case ollamaTagsEndPoint:
+			var tags ollamaTagsResponse
+			for name := range s.models {
+				tags.Models = append(tags.Models, struct {
+					Name string `json:"name"`
+				}{Name: name})
+			}
+			_ = json.NewEncoder(w).Encode(tags)
+		case ollamaShowEndPoint:
+			var req ollamaModelRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if !s.models[req.Model] {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error": "model not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"modelfile": "FROM llama3.1:8b"}`))
+		case ollamaPullEndPoint:
+			var req ollamaModelRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			s.pulls++
+			s.models[req.Model] = true
+			_, _ = w.Write([]byte(`{"status": "success"}`))
+		case ollamaChatEndPoint:
+			if err := json.NewDecoder(r.Body).Decode(&s.chat); err != nil || !s.models[s.chat.Model] {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"model": "llama3.1:8b", "message": {"role": "assistant", "content": "the image tag does not exist"}, "done": true, "done_reason": "stop"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newOllamaTestClient(t *testing.T, baseURL string, settings *v1alpha2.OllamaSettings) *HttpClient {
+	client, err := NewHttpClient(&v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "ollama"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:   v1alpha2.AuthProviderTypeOllama,
+			Auth:   v1alpha2.Auth{BaseURL: baseURL},
+			Ollama: settings,
+		},
+	}, &v1.Secret{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestOllamaProvider(t *testing.T) {
+	stub := &ollamaStub{models: map[string]bool{"llama3.1:8b": true}}
+	server := stub.serve(t)
+	client := newOllamaTestClient(t, server.URL, &v1alpha2.OllamaSettings{
+		LLMSettings: v1alpha2.LLMSettings{Model: "llama3.1:8b", Temperature: "0.1", MaxTokens: 256},
+	})
+	if client.Timeout != ollamaDefaultTimeout {
+		t.Errorf("expected the default deadline of local models, got %v", client.Timeout)
+	}
+
+	provider := NewProvider(client)
+	header, err := provider.AuthorizationHeader(context.Background())
+	if err != nil || header != "" {
+		t.Fatalf("expected no authorization without a key, got %q, %v", header, err)
+	}
+	answer, err := provider.Analyze(context.Background(), header, &AnalysisRequest{Instructions: "answer in JSON", Context: "pods are crash looping"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answer.Text != "the image tag does not exist" {
+		t.Errorf("expected the content of the message, got %q", answer.Text)
+	}
+	if stub.chat.Stream || stub.chat.Options == nil || *stub.chat.Options.Temperature != 0.1 || stub.chat.Options.NumPredict != 256 {
+		t.Errorf("expected a non-streaming request with the model options, got %+v", stub.chat)
+	}
+	if len(stub.chat.Messages) != 2 || stub.chat.Messages[0].Role != "system" || stub.chat.Messages[1].Content != "pods are crash looping" {
+		t.Errorf("expected the instructions as system message and the context as user message, got %+v", stub.chat.Messages)
+	}
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("expected the probe to find the model, got %v", err)
+	}
+}
+
+func TestOllamaProviderMissingModel(t *testing.T) {
+	stub := &ollamaStub{models: map[string]bool{}}
+	server := stub.serve(t)
+	client := newOllamaTestClient(t, server.URL, &v1alpha2.OllamaSettings{
+		LLMSettings: v1alpha2.LLMSettings{Model: "llama3.1:8b", Timeout: &metav1.Duration{Duration: time.Hour}},
+	})
+	if client.Timeout != time.Hour {
+		t.Errorf("expected the deadline of the AuthProvider, got %v", client.Timeout)
+	}
+	if err := client.Probe(context.Background()); err == nil {
+		t.Error("expected the probe to fail without the model")
+	}
+
+	_, err := NewProvider(client).Analyze(context.Background(), "", &AnalysisRequest{Context: "pods are crash looping"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || stub.pulls != 0 {
+		t.Errorf("expected a missing model to fail without a pull, got %v after %d pulls", err, stub.pulls)
+	}
+}
+
+func TestOllamaProviderPullsModel(t *testing.T) {
+	stub := &ollamaStub{models: map[string]bool{}}
+	server := stub.serve(t)
+	client := newOllamaTestClient(t, server.URL, &v1alpha2.OllamaSettings{
+		LLMSettings: v1alpha2.LLMSettings{Model: "llama3.1:8b"},
+		PullModel:   true,
+	})
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("expected the probe to accept a server that may pull the model, got %v", err)
+	}
+
+	provider := NewProvider(client)
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Analyze(context.Background(), "", &AnalysisRequest{Context: "pods are crash looping"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if stub.pulls != 1 {
+		t.Errorf("expected the model to be pulled once, got %d pulls", stub.pulls)
+	}
+}
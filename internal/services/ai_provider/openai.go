@@ -0,0 +1,109 @@
+package ai_provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// chatCompletionsEndPoint is appended to the base URL of openai providers, which includes the
+// version segment as in the OpenAI client libraries, e.g. https://api.openai.com/v1
+const chatCompletionsEndPoint = "/chat/completions"
+
+// ChatCompletionsProvider analyzes failures through the OpenAI chat completions API, as served by
+// OpenAI and compatible servers such as vLLM and LocalAI
+type ChatCompletionsProvider struct {
+	client HttpClient
+}
+
+var _ Provider = &ChatCompletionsProvider{}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	MaxTokens   int32         `json:"max_tokens,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// AuthorizationHeader returns the credentials of the scheme of the AuthProvider, by default the API
+// key as bearer token, servers without authentication get no header when the key is empty
+func (p *ChatCompletionsProvider) AuthorizationHeader(ctx context.Context) (string, error) {
+	return p.client.AuthorizationHeader(ctx)
+}
+
+// Analyze sends the instructions as system message and the context as user message and returns
+// the content of the first choice
+func (p *ChatCompletionsProvider) Analyze(ctx context.Context, authorizationHeader string, request *AnalysisRequest) (*Answer, error) {
+	logger := log.FromContext(ctx)
+	if request.Context == "" {
+		return nil, fmt.Errorf("skipping the chat completion request due to no context")
+	}
+
+	completionRequest := chatCompletionRequest{
+		Model:       p.client.Model,
+		Temperature: p.client.Temperature,
+		MaxTokens:   p.client.MaxTokens,
+	}
+	if request.Instructions != "" {
+		completionRequest.Messages = append(completionRequest.Messages, chatMessage{Role: "system", Content: request.Instructions})
+	}
+	completionRequest.Messages = append(completionRequest.Messages, chatMessage{Role: "user", Content: request.Context})
+	body, err := json.Marshal(completionRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.client.BaseURL+chatCompletionsEndPoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	p.client.SetAuthorization(req, authorizationHeader)
+	for key, value := range p.client.Headers {
+		req.Header.Add(key, value)
+	}
+
+	resp, err := p.client.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		logger.Info("received error status from chat completions", "status-code", resp.StatusCode, "model", p.client.Model)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return nil, &InvalidResponseError{Err: fmt.Errorf("invalid chat completion response: %w", err)}
+	}
+	if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
+		return nil, &InvalidResponseError{Err: fmt.Errorf("chat completion returned no answer")}
+	}
+	if reason := completion.Choices[0].FinishReason; reason == "length" {
+		logger.Info("chat completion was cut off by the token limit", "model", p.client.Model, "maxTokens", p.client.MaxTokens)
+	}
+	return &Answer{Text: completion.Choices[0].Message.Content}, nil
+}
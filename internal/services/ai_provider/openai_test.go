@@ -0,0 +1,148 @@
+package ai_provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// chatCompletionsStub answers chat completion requests like OpenAI, vLLM or LocalAI do
+func chatCompletionsStub(t *testing.T, answer string, got *chatCompletionRequest, authorization *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		*authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"choices": []interface{}{map[string]interface{}{"index": 0, "message": map[string]string{"role": "assistant", "content": answer}, "finish_reason": "stop"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChatCompletionsProvider(t *testing.T) {
+	var got chatCompletionRequest
+	var authorization string
+	server := chatCompletionsStub(t, `{"rootCause": "image tag does not exist"}`, &got, &authorization)
+
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "openai"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:   v1alpha2.AuthProviderTypeOpenAI,
+			Auth:   v1alpha2.Auth{BaseURL: server.URL + "/v1/"},
+			OpenAI: &v1alpha2.LLMSettings{Model: "gpt-4o-mini", Temperature: "0.2", MaxTokens: 512, Headers: map[string]string{"OpenAI-Organization": "org"}},
+		},
+	}
+	client, err := NewHttpClient(authProvider, &v1.Secret{Data: map[string][]byte{AppSecretKey: []byte("sk-test")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewProvider(client)
+	header, err := provider.AuthorizationHeader(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := provider.Analyze(context.Background(), header, &AnalysisRequest{Instructions: "answer in JSON", Context: "pods are crash looping"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if answer.Text != `{"rootCause": "image tag does not exist"}` {
+		t.Errorf("expected the content of the first choice, got %q", answer.Text)
+	}
+	if authorization != "Bearer sk-test" {
+		t.Errorf("expected the API key as bearer token, got %q", authorization)
+	}
+	if got.Model != "gpt-4o-mini" || got.Temperature == nil || *got.Temperature != 0.2 || got.MaxTokens != 512 {
+		t.Errorf("expected the model settings of the AuthProvider, got %+v", got)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.Messages[1].Content != "pods are crash looping" {
+		t.Errorf("expected the instructions as system message and the context as user message, got %+v", got.Messages)
+	}
+}
+
+func TestChatCompletionsProviderWithoutKey(t *testing.T) {
+	var got chatCompletionRequest
+	var authorization string
+	server := chatCompletionsStub(t, "the image tag does not exist", &got, &authorization)
+
+	provider := NewProvider(&HttpClient{Type: v1alpha2.AuthProviderTypeOpenAI, BaseURL: server.URL + "/v1", Model: "llama-3-8b"})
+	header, _ := provider.AuthorizationHeader(context.Background())
+	answer, err := provider.Analyze(context.Background(), header, &AnalysisRequest{Context: "pods are crash looping"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if authorization != "" || answer.Text != "the image tag does not exist" {
+		t.Errorf("expected an unauthenticated request answered with free text, got %q, %q", authorization, answer.Text)
+	}
+	if got.Temperature != nil || got.MaxTokens != 0 || len(got.Messages) != 1 {
+		t.Errorf("expected the server defaults and no system message, got %+v", got)
+	}
+}
+
+func TestChatCompletionsProviderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices": []}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&HttpClient{Type: v1alpha2.AuthProviderTypeOpenAI, BaseURL: server.URL, Model: "gpt-4o-mini"})
+	_, err := provider.Analyze(context.Background(), "Bearer wrong", &AnalysisRequest{Context: "pods are crash looping"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401 status error, got %v", err)
+	}
+
+	_, err = provider.Analyze(context.Background(), "Bearer sk-test", &AnalysisRequest{Context: "pods are crash looping"})
+	var invalid *InvalidResponseError
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an invalid response error without choices, got %v", err)
+	}
+}
+
+func TestAnalyzeEndpointAnswer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != analyzeEndPoint {
+			_, _ = w.Write([]byte(`{"analyses": []}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"analyses": [{"analysis": "pods are crash looping", "rootCause": "bad image"}]}`))
+	}))
+	defer server.Close()
+
+	client := &HttpClient{Type: v1alpha2.AuthProviderTypeWebhook, BaseURL: server.URL}
+	answer, err := NewProvider(client).Analyze(context.Background(), "Bearer token", &AnalysisRequest{Context: "context"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answer.Text != "pods are crash looping" || answer.Fields["rootCause"] != "bad image" {
+		t.Errorf("expected the first analysis with its fields, got %+v", answer)
+	}
+
+	client.APIVersion = "v2"
+	_, err = client.Analyze(context.Background(), "Bearer token", &AnalysisRequest{Context: "context"})
+	var invalid *InvalidResponseError
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an invalid response error for an empty analyses list, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package ai_provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ClientPool shares the clients of AuthProviders between analyses. The AuthProvider controller
+// keeps it current through Update as AuthProviders and their Secrets change, analyses build the
+// client of an AuthProvider the pool has not seen with its current generation, Secret and TLS
+// material on first use.
+// A nil ClientPool builds a new client for every analysis.
+type ClientPool struct {
+	mu      sync.RWMutex
+	entries map[types.NamespacedName]*pooledClient
+}
+
+type pooledClient struct {
+	client *HttpClient
+	// generation, secretVersion and tlsVersion are those of the AuthProvider, Secret and TLS
+	// material the client was built from
+	generation    int64
+	secretVersion string
+	tlsVersion    string
+}
+
+// NewClientPool creates an empty ClientPool
+func NewClientPool() *ClientPool {
+	return &ClientPool{entries: map[types.NamespacedName]*pooledClient{}}
+}
+
+// Update returns the client of the AuthProvider, rebuilding it when the AuthProvider, its Secret or
+// its TLS material changed since the pooled one was built. changed reports whether a pooled client
+// was replaced, the connections of the replaced client are closed once idle.
+func (p *ClientPool) Update(authProvider *v1alpha2.AuthProvider, secret *v1.Secret, material *TLSMaterial) (httpClient *HttpClient, changed bool, err error) {
+	key := types.NamespacedName{Namespace: authProvider.Namespace, Name: authProvider.Name}
+	if p != nil {
+		p.mu.RLock()
+		entry, ok := p.entries[key]
+		p.mu.RUnlock()
+		if ok && entry.generation == authProvider.Generation && entry.secretVersion == secret.ResourceVersion && entry.tlsVersion == material.version() {
+			return entry.client, false, nil
+		}
+	}
+
+	httpClient, err = NewHttpClient(authProvider, secret, material)
+	if err != nil {
+		p.Remove(key)
+		return nil, false, err
+	}
+	if p == nil {
+		return httpClient, false, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	replaced, changed := p.entries[key]
+	p.entries[key] = &pooledClient{client: httpClient, generation: authProvider.Generation, secretVersion: secret.ResourceVersion, tlsVersion: material.version()}
+	if changed {
+		replaced.client.HTTPClient().CloseIdleConnections()
+	}
+	return httpClient, changed, nil
+}
+
+// Remove drops the client and the cached credentials of the AuthProvider
+func (p *ClientPool) Remove(authProvider types.NamespacedName) {
+	DefaultCredentialCache.Forget(authProvider)
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[authProvider]; ok {
+		entry.client.HTTPClient().CloseIdleConnections()
+		delete(p.entries, authProvider)
+	}
+}
+
+// Client returns the client of the AuthProvider. Its Secret and TLS material are read on every
+// call, as the AuthProvider controller may not have seen them change yet, and the client is built
+// when the pool holds none for the generation of the AuthProvider and their resource versions.
+func (p *ClientPool) Client(ctx context.Context, k8sClient client.Client, authProvider *v1alpha2.AuthProvider) (*HttpClient, error) {
+	secret := &v1.Secret{}
+	if authProvider.Spec.SecretRef != nil {
+		var err error
+		if secret, err = utils.GetSecret(ctx, k8sClient, authProvider); err != nil {
+			log.FromContext(ctx).Error(err, "failed to get Secret from AuthProvider", "namespace", authProvider.Namespace, "name", authProvider.Name)
+			return nil, err
+		}
+	}
+	material, err := GetTLSMaterial(ctx, k8sClient, authProvider)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to get TLS material of AuthProvider", "namespace", authProvider.Namespace, "name", authProvider.Name)
+		return nil, err
+	}
+	httpClient, _, err := p.Update(authProvider, secret, material)
+	return httpClient, err
+}
+
+// ClientForRole returns the client of the first of the resolved AuthProviders whose type fills role
+func (p *ClientPool) ClientForRole(ctx context.Context, k8sClient client.Client, authProviders *[]v1alpha2.AuthProvider, role v1alpha2.ProviderRole, namespace string) (*HttpClient, error) {
+	for _, authProvider := range *authProviders {
+		if authProvider.Spec.Type.Role() == role {
+			return p.Client(ctx, k8sClient, &authProvider)
+		}
+	}
+	return nil, &ConfigurationError{Err: fmt.Errorf("no authProvider with role %q is referenced by the workflow in namespace %s", role, namespace)}
+}
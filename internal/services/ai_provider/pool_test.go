@@ -0,0 +1,107 @@
+package ai_provider
+
+import (
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestClientPoolUpdate(t *testing.T) {
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "argocd", Generation: 1},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:   v1alpha2.AuthProviderTypeArgoCD,
+			Auth:   v1alpha2.Auth{BaseURL: "https://argocd"},
+			ArgoCD: &v1alpha2.ArgoCDSettings{AppNamespace: "apps"},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data:       map[string][]byte{AppSecretKey: []byte("token")},
+	}
+
+	pool := NewClientPool()
+	client, changed, err := pool.Update(authProvider, secret, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Error("expected the first client not to be reported as changed")
+	}
+
+	if pooled, changed, _ := pool.Update(authProvider, secret, nil); pooled != client || changed {
+		t.Error("expected the pooled client while nothing changed")
+	}
+
+	secret = secret.DeepCopy()
+	secret.ResourceVersion = "2"
+	secret.Data[AppSecretKey] = []byte("rotated")
+	rotated, changed, err := pool.Update(authProvider, secret, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed || rotated == client || rotated.AppSecret != "rotated" {
+		t.Errorf("expected a client with the rotated secret, got %+v", rotated)
+	}
+
+	authProvider = authProvider.DeepCopy()
+	authProvider.Generation = 2
+	authProvider.Spec.Auth.BaseURL = "https://argocd.example"
+	moved, changed, err := pool.Update(authProvider, secret, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed || moved.BaseURL != "https://argocd.example" {
+		t.Errorf("expected a client with the updated base URL, got %+v", moved)
+	}
+
+	authProvider.Spec.TLS = &v1alpha2.TLSSettings{InsecureSkipVerify: true}
+	if _, changed, err := pool.Update(authProvider, secret, &TLSMaterial{Version: "1"}); err != nil || !changed {
+		t.Errorf("expected a new client when the TLS material changed, got %v", err)
+	}
+
+	authProvider = authProvider.DeepCopy()
+	authProvider.Generation = 3
+	authProvider.Spec.Type = ""
+	if _, _, err := pool.Update(authProvider, secret, nil); err == nil {
+		t.Error("expected an error for an AuthProvider without type")
+	}
+	if len(pool.entries) != 0 {
+		t.Error("expected the client of an invalid AuthProvider to be dropped")
+	}
+}
+
+func TestClientPoolRemove(t *testing.T) {
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "argocd", Generation: 1},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type: v1alpha2.AuthProviderTypeArgoCD,
+			Auth: v1alpha2.Auth{BaseURL: "https://argocd"},
+		},
+	}
+	secret := &v1.Secret{Data: map[string][]byte{AppSecretKey: []byte("token")}}
+
+	pool := NewClientPool()
+	client, _, err := pool.Update(authProvider, secret, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pool.Remove(types.NamespacedName{Namespace: "default", Name: "argocd"})
+
+	rebuilt, changed, err := pool.Update(authProvider, secret, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rebuilt == client || changed {
+		t.Error("expected a new client after the AuthProvider was removed")
+	}
+
+	var nilPool *ClientPool
+	if _, _, err := nilPool.Update(authProvider, secret, nil); err != nil {
+		t.Errorf("expected a nil pool to build clients, got %v", err)
+	}
+	nilPool.Remove(types.NamespacedName{Namespace: "default", Name: "argocd"})
+}
@@ -0,0 +1,114 @@
+package ai_provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+)
+
+// analyzeEndPoint is the endpoint of the GenAI analyze protocol spoken by genai and webhook providers
+const analyzeEndPoint = "/analyze"
+
+// AnalysisRequest is what a workflow asks a provider to analyze
+type AnalysisRequest struct {
+	// Instructions tell the model how to analyze the context and how to shape its answer. Providers
+	// with their own prompt, such as the GenAI analyze service, ignore them.
+	Instructions string
+	// Context is the collected context of the failure
+	Context string
+}
+
+// Answer is the analysis returned by a provider
+type Answer struct {
+	// Text is the answer of the model
+	Text string
+	// Fields are structured parts of the answer some providers return next to the text
+	Fields map[string]interface{}
+}
+
+// Provider analyzes failures with an LLM
+type Provider interface {
+	// AuthorizationHeader returns the header authorizing the analysis requests, exchanging the
+	// configured credentials when the provider needs to
+	AuthorizationHeader(ctx context.Context) (string, error)
+	// Analyze sends the request to the model with the header returned by AuthorizationHeader
+	Analyze(ctx context.Context, authorizationHeader string, request *AnalysisRequest) (*Answer, error)
+}
+
+var _ Provider = &HttpClient{}
+
+// InvalidResponseError is returned when a provider answered with a response holding no analysis
+type InvalidResponseError struct {
+	Err error
+}
+
+func (e *InvalidResponseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidResponseError) Unwrap() error {
+	return e.Err
+}
+
+// NewProvider returns the provider speaking the API of the client's AuthProvider type
+func NewProvider(client *HttpClient) Provider {
+	switch client.Type {
+	case v1alpha2.AuthProviderTypeOpenAI:
+		return &ChatCompletionsProvider{client: *client}
+	case v1alpha2.AuthProviderTypeAnthropic:
+		return &MessagesProvider{client: *client}
+	case v1alpha2.AuthProviderTypeOllama:
+		return &OllamaProvider{client: *client}
+	default:
+		return client
+	}
+}
+
+// Analyze sends the context to the analyze endpoint and returns the first analysis it answers with
+func (client *HttpClient) Analyze(ctx context.Context, authorizationHeader string, request *AnalysisRequest) (*Answer, error) {
+	failures := Failures{Failures: []Failure{{Context: request.Context}}}
+	tokens, err := json.Marshal(failures)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.PostRequest(ctx, authorizationHeader, string(tokens), analyzeEndPoint)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := parseAnalyses(res)
+	if err != nil {
+		return nil, &InvalidResponseError{Err: err}
+	}
+	return answer, nil
+}
+
+// parseAnalyses extracts the first entry of the analyses the analyze endpoint answers with
+func parseAnalyses(res interface{}) (*Answer, error) {
+	summary, ok := res.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("type assertion to map[string]interface{} failed")
+	}
+
+	value, exists := summary["analyses"]
+	if !exists {
+		return nil, fmt.Errorf("key 'analyses' not found in the result")
+	}
+
+	analysesSlice, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("type assertion for 'analyses' as []interface{} failed")
+	}
+	if len(analysesSlice) == 0 {
+		return nil, fmt.Errorf("no analysis found in the result")
+	}
+
+	analysisMap, ok := analysesSlice[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("type assertion for individual analysis failed")
+	}
+
+	text, _ := analysisMap["analysis"].(string)
+	return &Answer{Text: text, Fields: analysisMap}, nil
+}
@@ -0,0 +1,154 @@
+package ai_provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TLSMaterial holds the certificates the tls settings of an AuthProvider reference
+type TLSMaterial struct {
+	// CABundle holds the PEM encoded certificates of the additional authorities
+	CABundle []byte
+	// ClientCertificate and ClientKey hold the PEM encoded client certificate and its key
+	ClientCertificate []byte
+	ClientKey         []byte
+	// Version changes whenever one of the objects the material is read from changes
+	Version string
+}
+
+func (m *TLSMaterial) version() string {
+	if m == nil {
+		return ""
+	}
+	return m.Version
+}
+
+// referencesTLSMaterial reports whether the tls settings of the AuthProvider name a CA bundle or a client certificate
+func referencesTLSMaterial(spec *v1alpha2.AuthProviderSpec) bool {
+	return spec.TLS != nil && (spec.TLS.CABundle != nil || spec.TLS.ClientCertificate != nil)
+}
+
+// GetTLSMaterial reads the CA bundle and the client certificate the tls settings of the
+// AuthProvider reference, nil when it references none
+func GetTLSMaterial(ctx context.Context, reader client.Reader, authProvider *v1alpha2.AuthProvider) (*TLSMaterial, error) {
+	if !referencesTLSMaterial(&authProvider.Spec) {
+		return nil, nil
+	}
+	settings := authProvider.Spec.TLS
+	material := &TLSMaterial{}
+	var versions []string
+
+	if caBundle := settings.CABundle; caBundle != nil {
+		switch {
+		case caBundle.ConfigMapKeyRef != nil:
+			ref := caBundle.ConfigMapKeyRef
+			configMap := &v1.ConfigMap{}
+			if err := reader.Get(ctx, types.NamespacedName{Namespace: authProvider.Namespace, Name: ref.Name}, configMap); err != nil {
+				return nil, fmt.Errorf("failed to get the CA bundle from ConfigMap %q: %w", ref.Name, err)
+			}
+			material.CABundle = []byte(configMap.Data[ref.Key])
+			if len(material.CABundle) == 0 {
+				return nil, fmt.Errorf("configMap %q has no %q key", ref.Name, ref.Key)
+			}
+			versions = append(versions, "configmap:"+configMap.ResourceVersion)
+		case caBundle.SecretKeyRef != nil:
+			ref := caBundle.SecretKeyRef
+			secret := &v1.Secret{}
+			if err := reader.Get(ctx, types.NamespacedName{Namespace: authProvider.Namespace, Name: ref.Name}, secret); err != nil {
+				return nil, fmt.Errorf("failed to get the CA bundle from Secret %q: %w", ref.Name, err)
+			}
+			material.CABundle = secret.Data[ref.Key]
+			if len(material.CABundle) == 0 {
+				return nil, fmt.Errorf("secret %q has no %q key", ref.Name, ref.Key)
+			}
+			versions = append(versions, "secret:"+secret.ResourceVersion)
+		}
+	}
+
+	if ref := settings.ClientCertificate; ref != nil {
+		secret := &v1.Secret{}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: authProvider.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("failed to get the client certificate from Secret %q: %w", ref.Name, err)
+		}
+		material.ClientCertificate = secret.Data[v1.TLSCertKey]
+		material.ClientKey = secret.Data[v1.TLSPrivateKeyKey]
+		if len(material.ClientCertificate) == 0 || len(material.ClientKey) == 0 {
+			return nil, fmt.Errorf("secret %q has no %q and %q keys", ref.Name, v1.TLSCertKey, v1.TLSPrivateKeyKey)
+		}
+		versions = append(versions, "certificate:"+secret.ResourceVersion)
+	}
+
+	material.Version = strings.Join(versions, ",")
+	return material, nil
+}
+
+// NewTransport builds the transport of an AuthProvider from its tls and proxy settings and the
+// material they reference. Every client of the AuthProvider shares it, which keeps connections
+// to the provider open between requests.
+func NewTransport(spec *v1alpha2.AuthProviderSpec, material *TLSMaterial) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if spec.ProxyURL != "" {
+		proxyURL, err := url.Parse(spec.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxyUrl: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// the settings apply on top of the defaults, which keep their dial, handshake and idle timeouts
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	if tlsConfig.MinVersion < tls.VersionTLS12 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	if spec.TLS != nil {
+		tlsConfig.InsecureSkipVerify = spec.TLS.InsecureSkipVerify
+	}
+	if material != nil && len(material.CABundle) > 0 {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(material.CABundle) {
+			return nil, fmt.Errorf("the CA bundle holds no PEM encoded certificate")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if material != nil && len(material.ClientCertificate) > 0 {
+		certificate, err := tls.X509KeyPair(material.ClientCertificate, material.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// requestTimeout returns the deadline of a request to the provider, its own analysis deadline when
+// that is longer than defaultRequestTimeout. The stage deadlines of an attempt are shorter by
+// default, it bounds the requests they leave unbounded.
+func (client *HttpClient) requestTimeout() time.Duration {
+	if client.Timeout > defaultRequestTimeout {
+		return client.Timeout
+	}
+	return defaultRequestTimeout
+}
+
+// HTTPClient returns an http.Client sending requests through the transport of the provider
+func (client *HttpClient) HTTPClient() *http.Client {
+	return &http.Client{Transport: client.Transport, Timeout: client.requestTimeout()}
+}
@@ -0,0 +1,249 @@
+package ai_provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTLSTestClient builds the client of a webhook AuthProvider with the given tls settings and material
+func newTLSTestClient(t *testing.T, baseURL string, settings *v1alpha2.TLSSettings, material *TLSMaterial) *HttpClient {
+	client, err := NewHttpClient(&v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeWebhook,
+		Auth: v1alpha2.Auth{BaseURL: baseURL},
+		TLS:  settings,
+	}}, &v1.Secret{}, material)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func serverCABundle(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+// newClientCertificate creates a self-signed client certificate and its key, PEM encoded
+func newClientCertificate(t *testing.T) ([]byte, []byte, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "argo-support"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		certificate
+}
+
+func TestTransportCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if err := newTLSTestClient(t, server.URL, nil, nil).Probe(context.Background()); err == nil {
+		t.Error("expected a server signed by a private CA to be rejected without the CA bundle")
+	}
+
+	settings := &v1alpha2.TLSSettings{CABundle: &v1alpha2.CABundleSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{}}}
+	client := newTLSTestClient(t, server.URL, settings, &TLSMaterial{CABundle: serverCABundle(server)})
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("expected the server to be trusted through the CA bundle, got %v", err)
+	}
+
+	client = newTLSTestClient(t, server.URL, &v1alpha2.TLSSettings{InsecureSkipVerify: true}, nil)
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("expected the server to be accepted with insecureSkipVerify, got %v", err)
+	}
+
+	if _, err := NewHttpClient(&v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeWebhook,
+		Auth: v1alpha2.Auth{BaseURL: server.URL},
+		TLS:  settings,
+	}}, &v1.Secret{}, &TLSMaterial{CABundle: []byte("not a certificate")}); err == nil {
+		t.Error("expected a CA bundle without certificates to be rejected")
+	}
+}
+
+func TestTransportClientCertificate(t *testing.T) {
+	certPEM, keyPEM, certificate := newClientCertificate(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(certificate)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	settings := &v1alpha2.TLSSettings{
+		CABundle:          &v1alpha2.CABundleSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{}},
+		ClientCertificate: &v1.LocalObjectReference{Name: "client"},
+	}
+	client := newTLSTestClient(t, server.URL, settings, &TLSMaterial{
+		CABundle:          serverCABundle(server),
+		ClientCertificate: certPEM,
+		ClientKey:         keyPEM,
+	})
+	if err := client.Probe(context.Background()); err != nil {
+		t.Errorf("expected the client certificate to be accepted, got %v", err)
+	}
+
+	client = newTLSTestClient(t, server.URL, settings, &TLSMaterial{CABundle: serverCABundle(server)})
+	if err := client.Probe(context.Background()); err == nil {
+		t.Error("expected the server to reject a client without certificate")
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := NewHttpClient(&v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type:     v1alpha2.AuthProviderTypeWebhook,
+		Auth:     v1alpha2.Auth{BaseURL: "http://analyzer.example.com"},
+		ProxyURL: proxy.URL,
+	}}, &v1.Secret{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Probe(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxied != "http://analyzer.example.com/" {
+		t.Errorf("expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestGetTLSMaterial(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "internal-ca"},
+			Data:       map[string]string{"ca.crt": "bundle"},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "client"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key")},
+		},
+	).Build()
+
+	authProvider := &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "genai"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type: v1alpha2.AuthProviderTypeGenAI,
+			Auth: v1alpha2.Auth{BaseURL: "https://genai.internal.example.com"},
+		},
+	}
+	if material, err := GetTLSMaterial(context.Background(), k8sClient, authProvider); err != nil || material != nil {
+		t.Errorf("expected no material without tls settings, got %+v, %v", material, err)
+	}
+
+	authProvider.Spec.TLS = &v1alpha2.TLSSettings{
+		CABundle: &v1alpha2.CABundleSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "internal-ca"},
+			Key:                  "ca.crt",
+		}},
+		ClientCertificate: &v1.LocalObjectReference{Name: "client"},
+	}
+	material, err := GetTLSMaterial(context.Background(), k8sClient, authProvider)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(material.CABundle) != "bundle" || string(material.ClientCertificate) != "cert" || string(material.ClientKey) != "key" || material.Version == "" {
+		t.Errorf("unexpected material %+v", material)
+	}
+
+	if _, err := NewHttpClient(authProvider, &v1.Secret{}, nil); err == nil {
+		t.Error("expected an error when the referenced material was not read")
+	}
+
+	authProvider.Spec.TLS.CABundle.ConfigMapKeyRef.Key = "missing"
+	if _, err := GetTLSMaterial(context.Background(), k8sClient, authProvider); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+
+	authProvider.Spec.TLS.CABundle = nil
+	authProvider.Spec.TLS.ClientCertificate.Name = "missing"
+	if _, err := GetTLSMaterial(context.Background(), k8sClient, authProvider); err == nil {
+		t.Error("expected an error for a missing Secret")
+	}
+}
+
+func TestTransportTimeouts(t *testing.T) {
+	settings := &v1alpha2.TLSSettings{InsecureSkipVerify: true}
+	client := newTLSTestClient(t, "https://analyzer.example.com", settings, nil)
+	transport := client.Transport.(*http.Transport)
+	defaults := http.DefaultTransport.(*http.Transport)
+
+	if transport.DialContext == nil || transport.Proxy == nil {
+		t.Error("expected the transport to keep the dialer and proxy of the default transport")
+	}
+	if transport.TLSHandshakeTimeout != defaults.TLSHandshakeTimeout || transport.IdleConnTimeout != defaults.IdleConnTimeout ||
+		transport.ExpectContinueTimeout != defaults.ExpectContinueTimeout || transport.MaxIdleConns != defaults.MaxIdleConns {
+		t.Errorf("expected the transport to keep the timeouts of the default transport, got %+v", transport)
+	}
+	if !transport.TLSClientConfig.InsecureSkipVerify || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected the tls settings to apply on top of the defaults, got %+v", transport.TLSClientConfig)
+	}
+	if transport.ResponseHeaderTimeout != defaultRequestTimeout || client.HTTPClient().Timeout != defaultRequestTimeout {
+		t.Errorf("expected requests to be bounded by %s, got %s and %s", defaultRequestTimeout, transport.ResponseHeaderTimeout, client.HTTPClient().Timeout)
+	}
+
+	// a slower model brings a longer deadline for its analyses
+	ollama, err := NewHttpClient(&v1alpha2.AuthProvider{Spec: v1alpha2.AuthProviderSpec{
+		Type: v1alpha2.AuthProviderTypeOllama,
+		Auth: v1alpha2.Auth{BaseURL: "http://ollama.llm.svc:11434"},
+		Ollama: &v1alpha2.OllamaSettings{LLMSettings: v1alpha2.LLMSettings{
+			Model:   "llama3.1:70b",
+			Timeout: &metav1.Duration{Duration: time.Hour},
+		}},
+	}}, &v1.Secret{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ollama.HTTPClient().Timeout != time.Hour || ollama.Transport.(*http.Transport).ResponseHeaderTimeout != time.Hour {
+		t.Errorf("expected requests to be bounded by the timeout of the provider, got %s", ollama.HTTPClient().Timeout)
+	}
+}
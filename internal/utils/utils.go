@@ -3,18 +3,20 @@ package utils
 import (
 	"context"
 	"fmt"
-	v1alpha1 "github.com/argoproj-labs/argo-support/api/v1alpha1"
+	v1alpha2 "github.com/argoproj-labs/argo-support/api/v1alpha2"
 	rolloutv1alpha1 "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-var authProviderMap = make(map[v1alpha1.NamespacedObjectReference]*v1alpha1.AuthProvider)
-
-func GetSecret(ctx context.Context, k8sClient client.Client, authProvider *v1alpha1.AuthProvider) (*v1.Secret, error) {
+func GetSecret(ctx context.Context, k8sClient client.Client, authProvider *v1alpha2.AuthProvider) (*v1.Secret, error) {
 	logger := log.FromContext(ctx)
 
 	var secret v1.Secret
@@ -36,66 +38,178 @@ func GetSecret(ctx context.Context, k8sClient client.Client, authProvider *v1alp
 	return &secret, nil
 }
 
-func getAuthProviders(ctx context.Context, k8sClient client.Client, labels map[string]string) (*[]v1alpha1.AuthProvider, error) {
+// MissingAuthProvidersError is returned when some of the referenced AuthProviders do not exist
+type MissingAuthProvidersError struct {
+	Refs []v1alpha2.NamespacedObjectReference
+}
+
+func (e *MissingAuthProvidersError) Error() string {
+	missing := make([]string, 0, len(e.Refs))
+	for _, ref := range e.Refs {
+		missing = append(missing, ref.Namespace+"/"+ref.Name)
+	}
+	return fmt.Sprintf("authProvider not found: %s", strings.Join(missing, ", "))
+}
+
+// ForbiddenAuthProvidersError is returned when some of the referenced AuthProviders do not allow
+// Supports of the namespace to use them
+type ForbiddenAuthProvidersError struct {
+	Refs      []v1alpha2.NamespacedObjectReference
+	Namespace string
+}
+
+func (e *ForbiddenAuthProvidersError) Error() string {
+	forbidden := make([]string, 0, len(e.Refs))
+	for _, ref := range e.Refs {
+		forbidden = append(forbidden, ref.Namespace+"/"+ref.Name)
+	}
+	return fmt.Sprintf("authProvider does not allow namespace %s in spec.allowedNamespaces: %s", e.Namespace, strings.Join(forbidden, ", "))
+}
+
+// GetAuthProviders resolves exactly the AuthProviders named in refs, in the order they are listed.
+// References without a namespace are resolved in the given namespace. When any reference cannot be
+// found a MissingAuthProvidersError listing all of them is returned, and when any AuthProvider in
+// another namespace does not allow the namespace a ForbiddenAuthProvidersError.
+func GetAuthProviders(ctx context.Context, k8sClient client.Client, refs *[]v1alpha2.NamespacedObjectReference, namespace string) (*[]v1alpha2.AuthProvider, error) {
 	logger := log.FromContext(ctx)
 
-	authProviderList := &v1alpha1.AuthProviderList{}
-	listOptions := []client.ListOption{
-		client.MatchingLabels(labels),
+	if refs == nil || len(*refs) == 0 {
+		return nil, fmt.Errorf("no authProvider references in workflow")
 	}
-	err := k8sClient.List(ctx, authProviderList, listOptions...)
-	if err != nil {
-		return nil, err
+
+	authProviders := make([]v1alpha2.AuthProvider, 0, len(*refs))
+	missing := &MissingAuthProvidersError{}
+	forbidden := &ForbiddenAuthProvidersError{Namespace: namespace}
+	for _, ref := range *refs {
+		objectKey := client.ObjectKey{
+			Namespace: ref.Namespace,
+			Name:      ref.Name,
+		}
+		if objectKey.Namespace == "" {
+			objectKey.Namespace = namespace
+		}
+
+		var authProvider v1alpha2.AuthProvider
+		err := k8sClient.Get(ctx, objectKey, &authProvider)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				missing.Refs = append(missing.Refs, v1alpha2.NamespacedObjectReference{Name: objectKey.Name, Namespace: objectKey.Namespace})
+				continue
+			}
+			logger.Error(err, "failed to get AuthProvider", "namespace", objectKey.Namespace, "name", objectKey.Name)
+			return nil, err
+		}
+		if !authProvider.AllowsNamespace(namespace) {
+			forbidden.Refs = append(forbidden.Refs, v1alpha2.NamespacedObjectReference{Name: objectKey.Name, Namespace: objectKey.Namespace})
+			continue
+		}
+		authProviders = append(authProviders, authProvider)
 	}
 
-	if len(authProviderList.Items) == 0 {
-		err = fmt.Errorf("authProvider not found with labels %#v", labels)
-		logger.Info(err.Error())
-		return nil, err
+	if len(missing.Refs) > 0 {
+		logger.Info(missing.Error())
+		return nil, missing
+	}
+	if len(forbidden.Refs) > 0 {
+		logger.Info(forbidden.Error())
+		return nil, forbidden
 	}
 
-	return &authProviderList.Items, nil
+	return &authProviders, nil
 }
 
-func GetAuthProviders(ctx context.Context, k8sClient client.Client, refs *[]v1alpha1.NamespacedObjectReference, namespace string) (*[]v1alpha1.AuthProvider, error) {
+// Keys read from the layered argo-support configuration
+const (
+	// ConfigKeyHelpSlack is the Slack channel offered for help with a result
+	ConfigKeyHelpSlack = "help.slack"
+	// ConfigKeyHelpSlackLegacy is the key read before help.slack was introduced
+	ConfigKeyHelpSlackLegacy = "slackSupport"
+	// ConfigKeyHelpPrefix prefixes help links, e.g. help.stackoverflow
+	ConfigKeyHelpPrefix = "help."
+	// ConfigKeyPromptPrefix prefixes prompts overriding the inline prompt of a step, e.g. prompt.logs
+	ConfigKeyPromptPrefix = "prompt."
+	// ConfigKeyMaxContextLength limits the number of characters of collected context sent for analysis
+	ConfigKeyMaxContextLength = "limits.maxContextLength"
+)
+
+// GetConfigMapRef returns the ConfigMap named by refs in the given namespace
+func GetConfigMapRef(ctx context.Context, k8sClient client.Client, refs *v1alpha2.ConfigMapRef, namespace string) (*v1.ConfigMap, error) {
 	logger := log.FromContext(ctx)
-	authProviders, err := getAuthProviders(ctx, k8sClient, map[string]string{v1alpha1.LabelKeyAppName: v1alpha1.LabelKeyAppNameValue})
+
+	var cm v1.ConfigMap
+	err := k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: refs.Name}, &cm)
 	if err != nil {
-		logger.Error(err, "failed to get AuthProvider", "namespace", namespace)
+		logger.Error(err, "failed to get ConfigMap", "namespace", namespace, "name", refs.Name)
 		return nil, err
 	}
-	return authProviders, nil
+	return &cm, nil
 }
 
-func getConfigMap(ctx context.Context, k8sClient client.Client, labels map[string]string) (*v1.ConfigMap, error) {
+// GetEffectiveConfig layers the data of the ConfigMap named by refs over the controller-wide
+// default ConfigMap. A missing default ConfigMap is tolerated, a missing referenced one is not.
+func GetEffectiveConfig(ctx context.Context, k8sClient client.Client, defaults types.NamespacedName, refs *v1alpha2.ConfigMapRef, namespace string) (map[string]string, error) {
 	logger := log.FromContext(ctx)
 
-	cmList := &v1.ConfigMapList{}
-	listOptions := []client.ListOption{
-		client.MatchingLabels(labels),
+	config := map[string]string{}
+	if defaults.Name != "" {
+		var cm v1.ConfigMap
+		err := k8sClient.Get(ctx, defaults, &cm)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if errors.IsNotFound(err) {
+			logger.Info("default ConfigMap not found", "namespace", defaults.Namespace, "name", defaults.Name)
+		}
+		for key, value := range cm.Data {
+			config[key] = value
+		}
 	}
-	err := k8sClient.List(ctx, cmList, listOptions...)
-	if err != nil {
-		return nil, err
+
+	if refs != nil && refs.Name != "" {
+		cm, err := GetConfigMapRef(ctx, k8sClient, refs, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range cm.Data {
+			config[key] = value
+		}
 	}
 
-	if len(cmList.Items) == 0 {
-		err = fmt.Errorf("ConfigMap not found with labels %#v", labels)
-		logger.Info(err.Error())
-		return nil, err
+	return config, nil
+}
+
+// GetHelp builds the help section of a result from the help.* keys of the configuration
+func GetHelp(config map[string]string) v1alpha2.Help {
+	help := v1alpha2.Help{
+		SlackChannel: config[ConfigKeyHelpSlack],
+	}
+	if help.SlackChannel == "" {
+		help.SlackChannel = config[ConfigKeyHelpSlackLegacy]
+	}
+	for key, value := range config {
+		if strings.HasPrefix(key, ConfigKeyHelpPrefix) && key != ConfigKeyHelpSlack {
+			help.Links = append(help.Links, value)
+		}
 	}
+	sort.Strings(help.Links)
+	return help
+}
 
-	return &cmList.Items[0], nil
+// GetPrompt returns the prompt configured for step, falling back to the inline prompt
+func GetPrompt(config map[string]string, step string) string {
+	if prompt, ok := config[ConfigKeyPromptPrefix+step]; ok {
+		return prompt
+	}
+	return GetInlinePrompt(step, "")
 }
 
-func GetConfigMapRef(ctx context.Context, k8sClient client.Client, refs *v1alpha1.ConfigMapRef, namespace string) (*v1.ConfigMap, error) {
-	logger := log.FromContext(ctx)
-	cm, err := getConfigMap(ctx, k8sClient, map[string]string{v1alpha1.LabelKeyAppName: v1alpha1.LabelKeyAppNameValue})
-	if err != nil {
-		logger.Error(err, "failed to get AuthProvider", "namespace", namespace)
-		return nil, err
+// GetMaxContextLength returns the configured context limit, zero when unlimited
+func GetMaxContextLength(config map[string]string) int {
+	limit, err := strconv.Atoi(config[ConfigKeyMaxContextLength])
+	if err != nil || limit < 0 {
+		return 0
 	}
-	return cm, nil
+	return limit
 }
 
 func StripTheKeys(obj metav1.Object) metav1.Object {
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newAuthProvider(namespace, name string) *v1alpha2.AuthProvider {
+	return &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+}
+
+func TestGetAuthProviders(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newAuthProvider("tenant-a", "genai-auth-provider"),
+		newAuthProvider("tenant-b", "genai-auth-provider"),
+		&v1alpha2.AuthProvider{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shared", Name: "argocd-auth-provider"},
+			Spec:       v1alpha2.AuthProviderSpec{AllowedNamespaces: []string{"tenant-b"}},
+		},
+		&v1alpha2.AuthProvider{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shared", Name: "public"},
+			Spec:       v1alpha2.AuthProviderSpec{AllowedNamespaces: []string{v1alpha2.AllNamespaces}},
+		},
+	).Build()
+
+	refs := []v1alpha2.NamespacedObjectReference{
+		{Name: "genai-auth-provider"},
+		{Name: "argocd-auth-provider", Namespace: "shared"},
+	}
+	authProviders, err := GetAuthProviders(context.Background(), k8sClient, &refs, "tenant-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*authProviders) != 2 {
+		t.Fatalf("expected 2 AuthProviders, got %d", len(*authProviders))
+	}
+	if got := (*authProviders)[0]; got.Namespace != "tenant-b" || got.Name != "genai-auth-provider" {
+		t.Errorf("expected tenant-b/genai-auth-provider first, got %s/%s", got.Namespace, got.Name)
+	}
+	if got := (*authProviders)[1]; got.Namespace != "shared" {
+		t.Errorf("expected the argocd AuthProvider from namespace shared, got %s", got.Namespace)
+	}
+
+	refs = []v1alpha2.NamespacedObjectReference{
+		{Name: "genai-auth-provider"},
+		{Name: "missing"},
+		{Name: "argocd-auth-provider"},
+	}
+	_, err = GetAuthProviders(context.Background(), k8sClient, &refs, "tenant-a")
+	var missing *MissingAuthProvidersError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingAuthProvidersError, got %v", err)
+	}
+	if len(missing.Refs) != 2 {
+		t.Errorf("expected 2 missing references, got %v", missing.Refs)
+	}
+
+	// AuthProviders of other namespaces are only used by the namespaces they allow
+	refs = []v1alpha2.NamespacedObjectReference{
+		{Name: "public", Namespace: "shared"},
+		{Name: "argocd-auth-provider", Namespace: "shared"},
+		{Name: "genai-auth-provider", Namespace: "tenant-b"},
+	}
+	_, err = GetAuthProviders(context.Background(), k8sClient, &refs, "tenant-a")
+	var forbidden *ForbiddenAuthProvidersError
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("expected ForbiddenAuthProvidersError, got %v", err)
+	}
+	if len(forbidden.Refs) != 2 || forbidden.Refs[0].Name != "argocd-auth-provider" || forbidden.Refs[1].Namespace != "tenant-b" {
+		t.Errorf("expected the AuthProviders not allowing tenant-a to be reported, got %v", forbidden.Refs)
+	}
+}
+
+func TestGetEffectiveConfig(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "argo-support-system", Name: "argo-support-config"},
+			Data:       map[string]string{ConfigKeyHelpSlack: "#argo-support", ConfigKeyMaxContextLength: "100"},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "genai-cm"},
+			Data:       map[string]string{ConfigKeyHelpSlack: "#team-a", "prompt.logs": "only errors"},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "genai-cm"},
+			Data:       map[string]string{ConfigKeyHelpSlack: "#team-b"},
+		},
+	).Build()
+	defaults := types.NamespacedName{Namespace: "argo-support-system", Name: "argo-support-config"}
+
+	config, err := GetEffectiveConfig(context.Background(), k8sClient, defaults, &v1alpha2.ConfigMapRef{Name: "genai-cm"}, "team-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config[ConfigKeyHelpSlack] != "#team-a" || config[ConfigKeyMaxContextLength] != "100" {
+		t.Errorf("expected team-a values layered over the defaults, got %v", config)
+	}
+	if GetPrompt(config, "logs") != "only errors" || GetPrompt(config, "event") != GetInlinePrompt("event", "") {
+		t.Errorf("expected prompt override for logs only")
+	}
+
+	config, err = GetEffectiveConfig(context.Background(), k8sClient, types.NamespacedName{Name: "missing"}, nil, "team-b")
+	if err != nil {
+		t.Fatalf("unexpected error for a missing default ConfigMap: %v", err)
+	}
+	if len(config) != 0 {
+		t.Errorf("expected empty config, got %v", config)
+	}
+
+	if _, err := GetEffectiveConfig(context.Background(), k8sClient, defaults, &v1alpha2.ConfigMapRef{Name: "missing"}, "team-b"); err == nil {
+		t.Error("expected an error for a missing referenced ConfigMap")
+	}
+}
+
+func TestGetHelp(t *testing.T) {
+	help := GetHelp(map[string]string{
+		ConfigKeyHelpSlack:   "#argo-support",
+		"help.stackoverflow": "https://stackoverflow.com/search?q=argo",
+		"prompt.logs":        "ignored",
+	})
+	if help.SlackChannel != "#argo-support" {
+		t.Errorf("unexpected slack channel %q", help.SlackChannel)
+	}
+	if len(help.Links) != 1 || help.Links[0] != "https://stackoverflow.com/search?q=argo" {
+		t.Errorf("unexpected links %v", help.Links)
+	}
+}
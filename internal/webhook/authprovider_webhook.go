@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AuthProviderWebhook validates AuthProvider objects on admission
+type AuthProviderWebhook struct{}
+
+//+kubebuilder:webhook:path=/validate-argosupport-argoproj-extensions-io-v1alpha2-authprovider,mutating=false,failurePolicy=fail,sideEffects=None,groups=argosupport.argoproj.extensions.io,resources=authproviders,verbs=create;update,versions=v1alpha2,name=vauthprovider.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &AuthProviderWebhook{}
+
+// SetupWithManager registers the validating webhook of AuthProvider with the manager
+func (w *AuthProviderWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha2.AuthProvider{}).
+		WithValidator(w).
+		Complete()
+}
+
+// ValidateCreate rejects AuthProviders without a usable base URL or with settings of another type
+func (w *AuthProviderWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return w.validate(obj)
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate to the new object
+func (w *AuthProviderWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return w.validate(newObj)
+}
+
+// ValidateDelete allows every delete
+func (w *AuthProviderWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (w *AuthProviderWebhook) validate(obj runtime.Object) (admission.Warnings, error) {
+	authProvider, ok := obj.(*v1alpha2.AuthProvider)
+	if !ok {
+		return nil, fmt.Errorf("expected an AuthProvider but got %T", obj)
+	}
+
+	var warnings admission.Warnings
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := authProvider.Spec
+
+	baseURLPath := specPath.Child("auth", "baseUrl")
+	if spec.Auth.BaseURL == "" {
+		errs = append(errs, field.Required(baseURLPath, "the endpoint of the provider is required"))
+	} else if u, err := url.Parse(spec.Auth.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, field.Invalid(baseURLPath, spec.Auth.BaseURL, "must be an absolute URL"))
+	}
+
+	if spec.SecretRef == nil || spec.SecretRef.Name == "" {
+		warnings = append(warnings, "spec.secretRef is not set, the AuthProvider will not become Ready")
+	}
+
+	settings := []struct {
+		authProviderType v1alpha2.AuthProviderType
+		set              bool
+	}{
+		{v1alpha2.AuthProviderTypeGenAI, spec.GenAI != nil},
+		{v1alpha2.AuthProviderTypeArgoCD, spec.ArgoCD != nil},
+		{v1alpha2.AuthProviderTypeWebhook, spec.Webhook != nil},
+		{v1alpha2.AuthProviderTypePlugin, spec.Plugin != nil},
+		{v1alpha2.AuthProviderTypeOpenAI, spec.OpenAI != nil},
+		{v1alpha2.AuthProviderTypeAnthropic, spec.Anthropic != nil},
+		{v1alpha2.AuthProviderTypeOllama, spec.Ollama != nil},
+	}
+	for _, s := range settings {
+		if s.set && s.authProviderType != spec.Type {
+			errs = append(errs, field.Forbidden(specPath.Child(string(s.authProviderType)),
+				fmt.Sprintf("may only be set when type is %s", s.authProviderType)))
+		}
+	}
+
+	errs = append(errs, validateScheme(specPath.Child("auth"), &spec)...)
+	tlsErrs, tlsWarnings := validateTLS(specPath.Child("tls"), spec.TLS)
+	errs = append(errs, tlsErrs...)
+	warnings = append(warnings, tlsWarnings...)
+
+	if spec.ProxyURL != "" {
+		if u, err := url.Parse(spec.ProxyURL); err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+			errs = append(errs, field.Invalid(specPath.Child("proxyUrl"), spec.ProxyURL, "must be an absolute http or https URL"))
+		}
+	}
+
+	switch spec.Type {
+	case v1alpha2.AuthProviderTypeOpenAI:
+		errs = append(errs, validateLLMSettings(specPath.Child(string(spec.Type)), spec.OpenAI, 2)...)
+	case v1alpha2.AuthProviderTypeAnthropic:
+		errs = append(errs, validateLLMSettings(specPath.Child(string(spec.Type)), spec.Anthropic, 1)...)
+	case v1alpha2.AuthProviderTypeOllama:
+		var settings *v1alpha2.LLMSettings
+		if spec.Ollama != nil {
+			settings = &spec.Ollama.LLMSettings
+		}
+		errs = append(errs, validateLLMSettings(specPath.Child(string(spec.Type)), settings, 2)...)
+		if u, err := url.Parse(spec.Auth.BaseURL); err == nil && u.Host != "" && !inCluster(u.Hostname()) {
+			warnings = append(warnings, fmt.Sprintf("%s does not look like an address inside the cluster, the collected context leaves the cluster", u.Host))
+		}
+	}
+
+	if len(errs) == 0 {
+		return warnings, nil
+	}
+	return warnings, apierrors.NewInvalid(v1alpha2.GroupVersion.WithKind("AuthProvider").GroupKind(), authProvider.Name, errs)
+}
+
+// validateScheme checks that the settings of the authentication scheme match the scheme
+func validateScheme(path *field.Path, spec *v1alpha2.AuthProviderSpec) field.ErrorList {
+	var errs field.ErrorList
+	auth := spec.Auth
+	settings := []struct {
+		scheme v1alpha2.AuthScheme
+		set    bool
+	}{
+		{v1alpha2.AuthSchemeBearer, auth.Bearer != nil},
+		{v1alpha2.AuthSchemeBasic, auth.Basic != nil},
+		{v1alpha2.AuthSchemeAPIKey, auth.APIKey != nil},
+		{v1alpha2.AuthSchemeOAuth2, auth.OAuth2 != nil},
+		{v1alpha2.AuthSchemeIdentityService, auth.IdentityService != nil},
+	}
+	for _, s := range settings {
+		if s.set && s.scheme != auth.Scheme {
+			errs = append(errs, field.Forbidden(path.Child(string(s.scheme)), fmt.Sprintf("may only be set when scheme is %s", s.scheme)))
+		}
+	}
+
+	switch auth.Scheme {
+	case v1alpha2.AuthSchemeAPIKey:
+		if auth.APIKey == nil || auth.APIKey.Header == "" {
+			errs = append(errs, field.Required(path.Child("apiKey", "header"), "the header carrying the key is required"))
+		}
+	case v1alpha2.AuthSchemeOAuth2:
+		tokenURLPath := path.Child("oauth2", "tokenUrl")
+		if auth.OAuth2 == nil || auth.OAuth2.TokenURL == "" {
+			errs = append(errs, field.Required(tokenURLPath, "the token endpoint is required"))
+		} else if u, err := url.Parse(auth.OAuth2.TokenURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, field.Invalid(tokenURLPath, auth.OAuth2.TokenURL, "must be an absolute URL"))
+		}
+	case v1alpha2.AuthSchemeIdentityService:
+		hasEndpoint := auth.IdentityService != nil && auth.IdentityService.Endpoint != "" ||
+			spec.GenAI != nil && spec.GenAI.IdentityEndpoint != ""
+		if !hasEndpoint {
+			errs = append(errs, field.Required(path.Child("identityService", "endpoint"), "the identity service is required when spec.genai.identityEndpoint is not set"))
+		}
+	}
+	return errs
+}
+
+// validateTLS checks that the CA bundle names exactly one source and the referenced keys are set
+func validateTLS(path *field.Path, settings *v1alpha2.TLSSettings) (field.ErrorList, admission.Warnings) {
+	if settings == nil {
+		return nil, nil
+	}
+	var errs field.ErrorList
+	var warnings admission.Warnings
+	if caBundle := settings.CABundle; caBundle != nil {
+		caBundlePath := path.Child("caBundle")
+		switch {
+		case caBundle.ConfigMapKeyRef == nil && caBundle.SecretKeyRef == nil:
+			errs = append(errs, field.Required(caBundlePath, "one of configMapKeyRef and secretKeyRef is required"))
+		case caBundle.ConfigMapKeyRef != nil && caBundle.SecretKeyRef != nil:
+			errs = append(errs, field.Forbidden(caBundlePath, "only one of configMapKeyRef and secretKeyRef may be set"))
+		case caBundle.ConfigMapKeyRef != nil:
+			errs = append(errs, validateKeyRef(caBundlePath.Child("configMapKeyRef"), caBundle.ConfigMapKeyRef.Name, caBundle.ConfigMapKeyRef.Key)...)
+		default:
+			errs = append(errs, validateKeyRef(caBundlePath.Child("secretKeyRef"), caBundle.SecretKeyRef.Name, caBundle.SecretKeyRef.Key)...)
+		}
+		if settings.InsecureSkipVerify {
+			warnings = append(warnings, "spec.tls.caBundle is ignored while spec.tls.insecureSkipVerify is set")
+		}
+	}
+	if settings.ClientCertificate != nil && settings.ClientCertificate.Name == "" {
+		errs = append(errs, field.Required(path.Child("clientCertificate", "name"), "the Secret holding the client certificate is required"))
+	}
+	if settings.InsecureSkipVerify {
+		warnings = append(warnings, "spec.tls.insecureSkipVerify is set, the certificate of the provider is not verified")
+	}
+	return errs, warnings
+}
+
+func validateKeyRef(path *field.Path, name, key string) field.ErrorList {
+	var errs field.ErrorList
+	if name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "the name is required"))
+	}
+	if key == "" {
+		errs = append(errs, field.Required(path.Child("key"), "the key holding the bundle is required"))
+	}
+	return errs
+}
+
+// inCluster reports whether host is a Service name, a cluster domain or a private address
+func inCluster(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()
+	}
+	return !strings.Contains(host, ".") || strings.HasSuffix(host, ".svc") || strings.HasSuffix(host, ".cluster.local")
+}
+
+// validateLLMSettings checks the model settings of an AuthProvider talking to an LLM API accepting
+// temperatures up to maxTemperature
+func validateLLMSettings(path *field.Path, settings *v1alpha2.LLMSettings, maxTemperature float64) field.ErrorList {
+	if settings == nil {
+		return field.ErrorList{field.Required(path, "the model is required")}
+	}
+	var errs field.ErrorList
+	if settings.Model == "" {
+		errs = append(errs, field.Required(path.Child("model"), "the model is required"))
+	}
+	if settings.Temperature != "" {
+		if temperature, err := strconv.ParseFloat(settings.Temperature, 64); err != nil || temperature < 0 || temperature > maxTemperature {
+			errs = append(errs, field.Invalid(path.Child("temperature"), settings.Temperature,
+				fmt.Sprintf("must be a number between 0 and %g", maxTemperature)))
+		}
+	}
+	return errs
+}
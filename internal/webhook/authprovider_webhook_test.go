@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAuthProviderValidate(t *testing.T) {
+	w := &AuthProviderWebhook{}
+	authProvider := &v1alpha2.AuthProvider{
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeArgoCD,
+			SecretRef: &v1.LocalObjectReference{Name: "argocd-secret"},
+			Auth:      v1alpha2.Auth{BaseURL: "https://argocd.example.com"},
+			ArgoCD:    &v1alpha2.ArgoCDSettings{AppNamespace: "argocd"},
+		},
+	}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err != nil {
+		t.Fatalf("expected a valid AuthProvider, got %v", err)
+	}
+
+	authProvider.Spec.Auth.BaseURL = "argocd"
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a relative base URL to be rejected")
+	}
+
+	authProvider.Spec.Auth.BaseURL = "https://argocd.example.com"
+	authProvider.Spec.GenAI = &v1alpha2.GenAISettings{AppID: "app"}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected genai settings on an argocd AuthProvider to be rejected")
+	}
+}
+
+func TestAuthProviderValidateLLMSettings(t *testing.T) {
+	w := &AuthProviderWebhook{}
+	authProvider := &v1alpha2.AuthProvider{
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeOpenAI,
+			SecretRef: &v1.LocalObjectReference{Name: "openai-secret"},
+			Auth:      v1alpha2.Auth{BaseURL: "https://api.openai.com/v1"},
+			OpenAI:    &v1alpha2.LLMSettings{Model: "gpt-4o-mini", Temperature: "0.2"},
+		},
+	}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err != nil {
+		t.Fatalf("expected a valid AuthProvider, got %v", err)
+	}
+
+	authProvider.Spec.OpenAI.Temperature = "2.5"
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a temperature above 2 to be rejected")
+	}
+
+	authProvider.Spec.OpenAI = nil
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected an openai AuthProvider without a model to be rejected")
+	}
+
+	authProvider.Spec.Type = v1alpha2.AuthProviderTypeAnthropic
+	authProvider.Spec.Anthropic = &v1alpha2.LLMSettings{Model: "claude-sonnet-4-5", Temperature: "1.5"}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a temperature above 1 to be rejected for anthropic")
+	}
+}
+
+func TestAuthProviderValidateOllama(t *testing.T) {
+	w := &AuthProviderWebhook{}
+	authProvider := &v1alpha2.AuthProvider{
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeOllama,
+			SecretRef: &v1.LocalObjectReference{Name: "ollama-secret"},
+			Auth:      v1alpha2.Auth{BaseURL: "http://ollama.llm.svc:11434"},
+			Ollama:    &v1alpha2.OllamaSettings{LLMSettings: v1alpha2.LLMSettings{Model: "llama3.1:8b"}},
+		},
+	}
+	warnings, err := w.ValidateCreate(context.Background(), authProvider)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("expected an in-cluster ollama AuthProvider to be valid without warnings, got %v, %v", warnings, err)
+	}
+
+	authProvider.Spec.Auth.BaseURL = "https://ollama.example.com"
+	if warnings, _ := w.ValidateCreate(context.Background(), authProvider); len(warnings) != 1 {
+		t.Errorf("expected a warning for an ollama server outside the cluster, got %v", warnings)
+	}
+
+	authProvider.Spec.Ollama.Model = ""
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected an ollama AuthProvider without a model to be rejected")
+	}
+}
+
+func TestAuthProviderValidateScheme(t *testing.T) {
+	w := &AuthProviderWebhook{}
+	authProvider := &v1alpha2.AuthProvider{
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeWebhook,
+			SecretRef: &v1.LocalObjectReference{Name: "webhook-secret"},
+			Auth: v1alpha2.Auth{
+				BaseURL: "https://analyzer.example.com",
+				Scheme:  v1alpha2.AuthSchemeOAuth2,
+				OAuth2:  &v1alpha2.OAuth2Auth{TokenURL: "https://login.example.com/oauth2/token", Scopes: []string{"analyze"}},
+			},
+		},
+	}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err != nil {
+		t.Fatalf("expected a valid AuthProvider, got %v", err)
+	}
+
+	authProvider.Spec.Auth.OAuth2.TokenURL = "/oauth2/token"
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a relative token URL to be rejected")
+	}
+
+	authProvider.Spec.Auth.Scheme = v1alpha2.AuthSchemeAPIKey
+	authProvider.Spec.Auth.OAuth2 = nil
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected an apiKey scheme without a header to be rejected")
+	}
+
+	authProvider.Spec.Auth.APIKey = &v1alpha2.APIKeyAuth{Header: "X-API-Key"}
+	authProvider.Spec.Auth.Basic = &v1alpha2.BasicAuth{}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected basic settings on an apiKey scheme to be rejected")
+	}
+}
+
+func TestAuthProviderValidateTLS(t *testing.T) {
+	w := &AuthProviderWebhook{}
+	authProvider := &v1alpha2.AuthProvider{
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:      v1alpha2.AuthProviderTypeGenAI,
+			SecretRef: &v1.LocalObjectReference{Name: "genai-secret"},
+			Auth:      v1alpha2.Auth{BaseURL: "https://genai.internal.example.com"},
+			TLS: &v1alpha2.TLSSettings{
+				CABundle: &v1alpha2.CABundleSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "internal-ca"},
+					Key:                  "ca.crt",
+				}},
+				ClientCertificate: &v1.LocalObjectReference{Name: "genai-client"},
+			},
+			ProxyURL: "http://egress.example.com:3128",
+		},
+	}
+	warnings, err := w.ValidateCreate(context.Background(), authProvider)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("expected a valid AuthProvider without warnings, got %v, %v", warnings, err)
+	}
+
+	authProvider.Spec.TLS.CABundle.SecretKeyRef = &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "internal-ca"},
+		Key:                  "ca.crt",
+	}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a CA bundle with two sources to be rejected")
+	}
+
+	authProvider.Spec.TLS.CABundle = &v1alpha2.CABundleSource{SecretKeyRef: &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "internal-ca"},
+	}}
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a CA bundle without key to be rejected")
+	}
+
+	authProvider.Spec.TLS = &v1alpha2.TLSSettings{InsecureSkipVerify: true}
+	warnings, err = w.ValidateCreate(context.Background(), authProvider)
+	if err != nil || len(warnings) != 1 {
+		t.Errorf("expected insecureSkipVerify to be accepted with a warning, got %v, %v", warnings, err)
+	}
+
+	authProvider.Spec.ProxyURL = "socks5://egress.example.com:1080"
+	if _, err := w.ValidateCreate(context.Background(), authProvider); err == nil {
+		t.Error("expected a proxy URL that is not http or https to be rejected")
+	}
+}
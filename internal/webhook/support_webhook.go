@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/utils"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SupportDefaults are the controller-wide values filled into workflows that leave them unset
+type SupportDefaults struct {
+	// RetryLimit is the number of attempts a workflow gets
+	RetryLimit int64
+	// Delay is the delay between attempts
+	Delay time.Duration
+	// AuthProviderRefs are used by workflows that reference no AuthProvider
+	AuthProviderRefs []v1alpha2.NamespacedObjectReference
+}
+
+// SupportWebhook defaults and validates Support objects on admission
+type SupportWebhook struct {
+	Client   client.Client
+	Defaults SupportDefaults
+}
+
+//+kubebuilder:webhook:path=/mutate-argosupport-argoproj-extensions-io-v1alpha2-support,mutating=true,failurePolicy=fail,sideEffects=None,groups=argosupport.argoproj.extensions.io,resources=supports,verbs=create;update,versions=v1alpha2,name=msupport.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-argosupport-argoproj-extensions-io-v1alpha2-support,mutating=false,failurePolicy=fail,sideEffects=None,groups=argosupport.argoproj.extensions.io,resources=supports,verbs=create;update,versions=v1alpha2,name=vsupport.kb.io,admissionReviewVersions=v1
+
+var (
+	_ webhook.CustomDefaulter = &SupportWebhook{}
+	_ webhook.CustomValidator = &SupportWebhook{}
+)
+
+// SetupWithManager registers the defaulting and validating webhooks of Support with the manager
+func (w *SupportWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha2.Support{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+// Default fills in the retry limit, delay and AuthProvider references of every workflow, and the
+// start time of the workflows of a Support that is created. An update leaves the start time alone,
+// as a new start time starts a new run.
+func (w *SupportWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	support, ok := obj.(*v1alpha2.Support)
+	if !ok {
+		return fmt.Errorf("expected a Support but got %T", obj)
+	}
+	log.FromContext(ctx).V(1).Info("defaulting Support", "namespace", support.Namespace, "name", support.Name)
+
+	creating := true
+	if req, err := admission.RequestFromContext(ctx); err == nil {
+		creating = req.Operation == admissionv1.Create
+	}
+
+	for i := range support.Spec.Workflows {
+		wf := &support.Spec.Workflows[i]
+		if wf.RetryLimit == 0 {
+			wf.RetryLimit = w.Defaults.RetryLimit
+		}
+		if wf.Delay == nil {
+			wf.Delay = &metav1.Duration{Duration: w.Defaults.Delay}
+		}
+		if wf.InitiatedAt == nil && creating {
+			now := metav1.Now()
+			wf.InitiatedAt = &now
+		}
+		if len(wf.AuthProviderRefs) == 0 {
+			wf.AuthProviderRefs = append([]v1alpha2.NamespacedObjectReference(nil), w.Defaults.AuthProviderRefs...)
+		}
+		for j := range wf.AuthProviderRefs {
+			if wf.AuthProviderRefs[j].Namespace == "" {
+				wf.AuthProviderRefs[j].Namespace = support.Namespace
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateCreate rejects Supports with unknown or duplicate workflows and missing AuthProviders
+func (w *SupportWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, w.validate(ctx, obj)
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate to the new object
+func (w *SupportWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, w.validate(ctx, newObj)
+}
+
+// ValidateDelete allows every delete
+func (w *SupportWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (w *SupportWebhook) validate(ctx context.Context, obj runtime.Object) error {
+	support, ok := obj.(*v1alpha2.Support)
+	if !ok {
+		return fmt.Errorf("expected a Support but got %T", obj)
+	}
+
+	var errs field.ErrorList
+	workflowsPath := field.NewPath("spec", "workflows")
+	if len(support.Spec.Workflows) == 0 {
+		errs = append(errs, field.Required(workflowsPath, "at least one workflow is required"))
+	}
+
+	seen := map[string]bool{}
+	for i, wf := range support.Spec.Workflows {
+		wfPath := workflowsPath.Index(i)
+		if seen[wf.Name] {
+			errs = append(errs, field.Duplicate(wfPath.Child("name"), wf.Name))
+		}
+		seen[wf.Name] = true
+
+		if wf.RetryLimit < 0 {
+			errs = append(errs, field.Invalid(wfPath.Child("retryLimit"), wf.RetryLimit, "must not be negative"))
+		}
+		if wf.Delay != nil && wf.Delay.Duration < 0 {
+			errs = append(errs, field.Invalid(wfPath.Child("delay"), wf.Delay, "must not be negative"))
+		}
+		for j, dependency := range wf.DependsOn {
+			switch {
+			case dependency == wf.Name:
+				errs = append(errs, field.Invalid(wfPath.Child("dependsOn").Index(j), dependency, "a workflow cannot depend on itself"))
+			case !hasWorkflow(support, dependency):
+				errs = append(errs, field.NotFound(wfPath.Child("dependsOn").Index(j), dependency))
+			}
+		}
+
+		refsPath := wfPath.Child("authProviderRefs")
+		if len(wf.AuthProviderRefs) == 0 {
+			if !wf_operations.IsRegistered(wf.Name) {
+				errs = append(errs, field.NotSupported(wfPath.Child("name"), wf.Name, wf_operations.RegisteredWorkflows()))
+			}
+			errs = append(errs, field.Required(refsPath, "at least one AuthProvider reference is required"))
+			continue
+		}
+		authProviders, err := utils.GetAuthProviders(ctx, w.Client, &wf.AuthProviderRefs, support.Namespace)
+		var missing *utils.MissingAuthProvidersError
+		var forbidden *utils.ForbiddenAuthProvidersError
+		switch {
+		case goerrors.As(err, &missing):
+			// a plugin among the missing AuthProviders may serve the workflow, only the references are reported
+			for _, ref := range missing.Refs {
+				errs = append(errs, field.NotFound(refsPath, ref.Namespace+"/"+ref.Name))
+			}
+		case goerrors.As(err, &forbidden):
+			for _, ref := range forbidden.Refs {
+				errs = append(errs, field.Forbidden(refsPath, fmt.Sprintf("authProvider %s/%s does not allow namespace %s in spec.allowedNamespaces",
+					ref.Namespace, ref.Name, support.Namespace)))
+			}
+		case err != nil:
+			return err
+		case !wf_operations.IsRunnable(wf.Name, *authProviders):
+			// workflows served by plugin AuthProviders are runnable too
+			errs = append(errs, field.NotSupported(wfPath.Child("name"), wf.Name, wf_operations.RegisteredWorkflows()))
+		}
+	}
+
+	if target := support.Spec.Target; target != nil && target.Namespace != "" && target.Namespace != support.Namespace {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "target", "namespace"), target.Namespace,
+			"must be the namespace of the Support"))
+	}
+
+	if cycle := dependencyCycle(support); cycle != nil {
+		errs = append(errs, field.Invalid(workflowsPath, cycle, "dependsOn must not form a cycle"))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(v1alpha2.GroupVersion.WithKind("Support").GroupKind(), support.Name, errs)
+}
+
+// hasWorkflow reports whether the Support lists the named workflow
+func hasWorkflow(support *v1alpha2.Support, name string) bool {
+	for _, wf := range support.Spec.Workflows {
+		if wf.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencyCycle returns the names of the workflows forming a dependsOn cycle, if there is one.
+// Workflows depending on themselves or on unknown workflows are reported on their own.
+func dependencyCycle(support *v1alpha2.Support) []string {
+	dependsOn := map[string][]string{}
+	for _, wf := range support.Spec.Workflows {
+		dependsOn[wf.Name] = wf.DependsOn
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i := range path {
+				if path[i] == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range dependsOn[name] {
+			if dependency == name {
+				continue
+			}
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, wf := range support.Spec.Workflows {
+		if cycle := visit(wf.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations/genai"
+	_ "github.com/argoproj-labs/argo-support/internal/wf_operations/plugin"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newSupportWebhook(t *testing.T, objs ...runtime.Object) *SupportWebhook {
+	scheme := runtime.NewScheme()
+	if err := v1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &SupportWebhook{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build(),
+		Defaults: SupportDefaults{
+			RetryLimit:       3,
+			Delay:            30 * time.Second,
+			AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "genai-auth-provider"}},
+		},
+	}
+}
+
+func TestSupportDefault(t *testing.T) {
+	w := newSupportWebhook(t)
+	support := &v1alpha2.Support{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "support"},
+		Spec: v1alpha2.SupportSpec{Workflows: []v1alpha2.Workflow{
+			{Name: genai.WorkflowName},
+			{Name: "other", RetryLimit: 5, AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "mine", Namespace: "shared"}}},
+		}},
+	}
+	if err := w.Default(context.Background(), support); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wf := support.Spec.Workflows[0]
+	if wf.RetryLimit != 3 || wf.Delay.Duration != 30*time.Second || wf.InitiatedAt == nil {
+		t.Errorf("expected controller defaults, got retryLimit %d delay %d initiatedAt %v", wf.RetryLimit, wf.Delay, wf.InitiatedAt)
+	}
+	if len(wf.AuthProviderRefs) != 1 || wf.AuthProviderRefs[0].Name != "genai-auth-provider" || wf.AuthProviderRefs[0].Namespace != "tenant-a" {
+		t.Errorf("expected the default AuthProvider in the Support namespace, got %v", wf.AuthProviderRefs)
+	}
+	if support.Spec.Workflows[1].RetryLimit != 5 || support.Spec.Workflows[1].AuthProviderRefs[0].Namespace != "shared" {
+		t.Errorf("expected explicit values to be kept, got %v", support.Spec.Workflows[1])
+	}
+}
+
+func TestSupportDefaultOnUpdate(t *testing.T) {
+	w := newSupportWebhook(t)
+	support := &v1alpha2.Support{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "support"},
+		Spec:       v1alpha2.SupportSpec{Workflows: []v1alpha2.Workflow{{Name: genai.WorkflowName}}},
+	}
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+	})
+	if err := w.Default(ctx, support); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wf := support.Spec.Workflows[0]
+	if wf.InitiatedAt != nil {
+		t.Errorf("expected an update to leave the start time unset, got %v", wf.InitiatedAt)
+	}
+	if wf.RetryLimit != 3 || len(wf.AuthProviderRefs) != 1 {
+		t.Errorf("expected the other defaults on update, got retryLimit %d refs %v", wf.RetryLimit, wf.AuthProviderRefs)
+	}
+}
+
+func TestSupportValidate(t *testing.T) {
+	w := newSupportWebhook(t, &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "genai-auth-provider"},
+	}, &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "triage-plugin"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:   v1alpha2.AuthProviderTypePlugin,
+			Plugin: &v1alpha2.PluginSettings{Workflows: []string{"triage"}},
+		},
+	}, &v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-b", Name: "genai-auth-provider"},
+	})
+	refs := []v1alpha2.NamespacedObjectReference{{Name: "genai-auth-provider"}}
+
+	support := &v1alpha2.Support{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "support"},
+		Spec:       v1alpha2.SupportSpec{Workflows: []v1alpha2.Workflow{{Name: genai.WorkflowName, AuthProviderRefs: refs}}},
+	}
+	if _, err := w.ValidateCreate(context.Background(), support); err != nil {
+		t.Fatalf("expected a valid Support, got %v", err)
+	}
+
+	support.Spec.Workflows = []v1alpha2.Workflow{{Name: "triage", AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "triage-plugin"}}}}
+	if _, err := w.ValidateCreate(context.Background(), support); err != nil {
+		t.Fatalf("expected a workflow served by a plugin to be valid, got %v", err)
+	}
+
+	support.Spec.Workflows = []v1alpha2.Workflow{
+		{Name: genai.WorkflowName, AuthProviderRefs: refs},
+		{Name: "triage", AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "triage-plugin"}}, DependsOn: []string{genai.WorkflowName}},
+	}
+	if _, err := w.ValidateCreate(context.Background(), support); err != nil {
+		t.Fatalf("expected a workflow depending on another to be valid, got %v", err)
+	}
+
+	support.Spec.Target = &v1alpha2.TargetRef{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout", Name: "rollout", Namespace: "kube-system"}
+	if _, err := w.ValidateCreate(context.Background(), support); err == nil || !strings.Contains(err.Error(), "spec.target.namespace") {
+		t.Errorf("expected a target in another namespace to be rejected, got %v", err)
+	}
+	support.Spec.Target.Namespace = support.Namespace
+	if _, err := w.ValidateCreate(context.Background(), support); err != nil {
+		t.Errorf("expected a target in the namespace of the Support to be valid, got %v", err)
+	}
+	support.Spec.Target = nil
+
+	support.Spec.Workflows[0].AuthProviderRefs = []v1alpha2.NamespacedObjectReference{{Name: "genai-auth-provider", Namespace: "tenant-b"}}
+	if _, err := w.ValidateCreate(context.Background(), support); err == nil || !strings.Contains(err.Error(), "does not allow namespace tenant-a") {
+		t.Errorf("expected an AuthProvider of another namespace not allowing it to be rejected, got %v", err)
+	}
+	support.Spec.Workflows[0].AuthProviderRefs = refs
+
+	support.Spec.Workflows[0].DependsOn = []string{"triage", "missing"}
+	_, err := w.ValidateCreate(context.Background(), support)
+	if err == nil {
+		t.Fatal("expected the Support to be rejected")
+	}
+	for _, want := range []string{"must not form a cycle", "dependsOn[1]: Not found"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+
+	support.Spec.Workflows = []v1alpha2.Workflow{
+		{Name: genai.WorkflowName, AuthProviderRefs: refs},
+		{Name: genai.WorkflowName, AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "missing"}}},
+		{Name: "unknown", AuthProviderRefs: refs},
+		{Name: "other", AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "triage-plugin"}}},
+	}
+	_, err = w.ValidateCreate(context.Background(), support)
+	if err == nil {
+		t.Fatal("expected the Support to be rejected")
+	}
+	for _, want := range []string{"Duplicate value", "tenant-a/missing", `Unsupported value: "unknown"`, `Unsupported value: "other"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+}
@@ -2,11 +2,12 @@ package wf_operations
 
 import (
 	"context"
-	"github.com/argoproj-labs/argo-support/api/v1alpha1"
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type Executor interface {
-	// Process execute the specific workflow	GetWfOperator(ctx context.Context, obj metav1.Object) (*v1alpha1.ArgoAISupport, error)
-	Process(ctx context.Context, obj metav1.Object) (*v1alpha1.Support, error)
+	// Process runs the workflow against the Support and returns the result of the analysis. The
+	// conditions of the Support are updated along the way, its results are left to the caller.
+	Process(ctx context.Context, obj metav1.Object) (*v1alpha2.Result, error)
 }
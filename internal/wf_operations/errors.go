@@ -0,0 +1,58 @@
+package wf_operations
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+)
+
+// PermanentError wraps a failure another attempt of the workflow cannot fix, such as bad configuration
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// ProviderClientError returns the error of building a provider client, marked permanent when the
+// configuration of the AuthProvider caused it
+func ProviderClientError(err error) error {
+	var configErr *ai_provider.ConfigurationError
+	if errors.As(err, &configErr) {
+		return Permanent(err)
+	}
+	return err
+}
+
+// IsRetryable reports whether another attempt may succeed where err failed. Permanent errors and
+// client errors returned by a provider are not retryable, server errors, throttling and timeouts are.
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var statusErr *ai_provider.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		default:
+			return statusErr.StatusCode >= http.StatusInternalServerError
+		}
+	}
+	return true
+}
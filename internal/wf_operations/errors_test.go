@@ -0,0 +1,30 @@
+package wf_operations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", fmt.Errorf("failed to post request: %w", &ai_provider.StatusError{StatusCode: 503}), true},
+		{"throttled", &ai_provider.StatusError{StatusCode: 429}, true},
+		{"client error", fmt.Errorf("failed to post request: %w", &ai_provider.StatusError{StatusCode: 401}), false},
+		{"timeout", fmt.Errorf("failed to post request: %w", context.DeadlineExceeded), true},
+		{"permanent", Permanent(fmt.Errorf("configMap not found")), false},
+		{"provider configuration", ProviderClientError(&ai_provider.ConfigurationError{Err: fmt.Errorf("no model")}), false},
+		{"provider unavailable", ProviderClientError(fmt.Errorf("failed to get Secret")), true},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: expected IsRetryable %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
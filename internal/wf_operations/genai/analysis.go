@@ -0,0 +1,187 @@
+package genai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/utils"
+)
+
+// structuredAnalysis is the structured answer a provider may return, either as the fields of the
+// analysis entry or as a JSON document in its analysis text
+type structuredAnalysis struct {
+	Summary           string            `json:"summary"`
+	RootCause         string            `json:"rootCause"`
+	Category          string            `json:"category"`
+	AffectedResources []json.RawMessage `json:"affectedResources"`
+	RemediationSteps  []string          `json:"remediationSteps"`
+	Confidence        json.RawMessage   `json:"confidence"`
+	Evidence          []string          `json:"evidence"`
+}
+
+// answerFormat asks models answering through a chat API for the structured form of the analysis
+const answerFormat = "Answer with a single JSON object and nothing else. Use the keys summary (a short description " +
+	"of the failure), rootCause, category (one of Configuration, Image, Resources, HealthCheck, Network, Dependency, " +
+	"Application or Unknown), affectedResources (a list of Kind/name strings), remediationSteps (a list of steps in " +
+	"order), confidence (low, medium or high) and evidence (a list of lines of the context the analysis is based on)."
+
+// analysisInstructions returns the instructions sent along with the context to providers that take them
+func analysisInstructions(config map[string]string) string {
+	return strings.TrimSpace(utils.GetPrompt(config, "main")) + " " + answerFormat
+}
+
+// parseAnswer extracts the analysis from the answer of the provider. The free text of the answer
+// is returned as summary, and when the answer is structured its parts are returned as analysis
+// as well.
+func parseAnswer(answer *ai_provider.Answer) (string, *v1alpha2.Analysis, error) {
+	if structured, ok := structuredFromFields(answer.Fields); ok {
+		return summaryOf(structured, answer.Text), toAnalysis(structured), nil
+	}
+	if structured, ok := structuredFromText(answer.Text); ok {
+		return summaryOf(structured, ""), toAnalysis(structured), nil
+	}
+	if answer.Text == "" {
+		return "", nil, fmt.Errorf("the provider answered without an analysis")
+	}
+	return answer.Text, nil, nil
+}
+
+// structuredFromFields reads the structured fields placed next to the analysis text
+func structuredFromFields(analysisMap map[string]interface{}) (*structuredAnalysis, bool) {
+	if _, ok := analysisMap["rootCause"]; !ok {
+		return nil, false
+	}
+	data, err := json.Marshal(analysisMap)
+	if err != nil {
+		return nil, false
+	}
+	structured := &structuredAnalysis{}
+	if err := json.Unmarshal(data, structured); err != nil {
+		return nil, false
+	}
+	return structured, true
+}
+
+// structuredFromText reads an analysis text holding a JSON document, optionally in a code fence
+func structuredFromText(text string) (*structuredAnalysis, bool) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	if !strings.HasPrefix(text, "{") {
+		return nil, false
+	}
+	structured := &structuredAnalysis{}
+	if err := json.Unmarshal([]byte(text), structured); err != nil || structured.RootCause == "" {
+		return nil, false
+	}
+	return structured, true
+}
+
+func summaryOf(structured *structuredAnalysis, text string) string {
+	switch {
+	case structured.Summary != "":
+		return structured.Summary
+	case text != "":
+		return text
+	default:
+		return structured.RootCause
+	}
+}
+
+func toAnalysis(structured *structuredAnalysis) *v1alpha2.Analysis {
+	analysis := &v1alpha2.Analysis{
+		RootCause:        structured.RootCause,
+		Category:         parseCategory(structured.Category),
+		RemediationSteps: structured.RemediationSteps,
+		Confidence:       parseConfidence(structured.Confidence),
+		Evidence:         structured.Evidence,
+	}
+	for _, raw := range structured.AffectedResources {
+		if ref, ok := parseObjectReference(raw); ok {
+			analysis.AffectedResources = append(analysis.AffectedResources, ref)
+		}
+	}
+	return analysis
+}
+
+var knownCategories = []v1alpha2.FailureCategory{
+	v1alpha2.FailureCategoryConfiguration,
+	v1alpha2.FailureCategoryImage,
+	v1alpha2.FailureCategoryResources,
+	v1alpha2.FailureCategoryHealthCheck,
+	v1alpha2.FailureCategoryNetwork,
+	v1alpha2.FailureCategoryDependency,
+	v1alpha2.FailureCategoryApplication,
+	v1alpha2.FailureCategoryUnknown,
+}
+
+// parseCategory matches the well known categories regardless of case and keeps any other value
+func parseCategory(category string) v1alpha2.FailureCategory {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return ""
+	}
+	for _, known := range knownCategories {
+		if strings.EqualFold(category, string(known)) {
+			return known
+		}
+	}
+	return v1alpha2.FailureCategory(category)
+}
+
+// parseConfidence accepts a level name or a score between 0 and 1 or 0 and 100
+func parseConfidence(raw json.RawMessage) v1alpha2.ConfidenceLevel {
+	if len(raw) == 0 {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		value = string(raw)
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch level := v1alpha2.ConfidenceLevel(value); level {
+	case v1alpha2.ConfidenceLow, v1alpha2.ConfidenceMedium, v1alpha2.ConfidenceHigh:
+		return level
+	}
+
+	score, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return ""
+	}
+	if score > 1 {
+		score /= 100
+	}
+	switch {
+	case score >= 0.75:
+		return v1alpha2.ConfidenceHigh
+	case score >= 0.4:
+		return v1alpha2.ConfidenceMedium
+	default:
+		return v1alpha2.ConfidenceLow
+	}
+}
+
+// parseObjectReference accepts an object with kind, name and namespace or a kind/name string
+func parseObjectReference(raw json.RawMessage) (v1alpha2.ObjectReference, bool) {
+	var ref v1alpha2.ObjectReference
+	if err := json.Unmarshal(raw, &ref); err == nil {
+		return ref, ref.Name != ""
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil || value == "" {
+		return ref, false
+	}
+	kind, name, found := strings.Cut(value, "/")
+	if !found {
+		return v1alpha2.ObjectReference{Name: value}, true
+	}
+	return v1alpha2.ObjectReference{Kind: kind, Name: name}, name != ""
+}
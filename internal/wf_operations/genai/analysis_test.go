@@ -0,0 +1,64 @@
+package genai
+
+import (
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+)
+
+func TestParseAnswerFreeText(t *testing.T) {
+	summary, analysis, err := parseAnswer(&ai_provider.Answer{Text: "pods are crash looping"})
+	if err != nil || summary != "pods are crash looping" || analysis != nil {
+		t.Errorf("expected the free text only, got %q, %+v, %v", summary, analysis, err)
+	}
+
+	if _, _, err := parseAnswer(&ai_provider.Answer{}); err == nil {
+		t.Error("expected an error for an empty answer")
+	}
+}
+
+func TestParseAnswerStructuredFields(t *testing.T) {
+	answer := &ai_provider.Answer{
+		Text: "the canary fails its readiness probe",
+		Fields: map[string]interface{}{
+			"analysis":          "the canary fails its readiness probe",
+			"rootCause":         "the readiness probe points at port 8081",
+			"category":          "healthcheck",
+			"affectedResources": []interface{}{"Rollout/guestbook", map[string]interface{}{"kind": "Pod", "name": "guestbook-abc", "namespace": "apps"}},
+			"remediationSteps":  []interface{}{"point the probe at port 8080"},
+			"confidence":        0.8,
+			"evidence":          []interface{}{"Readiness probe failed: connection refused"},
+		},
+	}
+	summary, analysis, err := parseAnswer(answer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary != "the canary fails its readiness probe" {
+		t.Errorf("expected the analysis text as summary, got %q", summary)
+	}
+	if analysis.Category != v1alpha2.FailureCategoryHealthCheck || analysis.Confidence != v1alpha2.ConfidenceHigh {
+		t.Errorf("expected a high confidence HealthCheck analysis, got %+v", analysis)
+	}
+	if len(analysis.AffectedResources) != 2 || analysis.AffectedResources[0].Kind != "Rollout" || analysis.AffectedResources[1].Namespace != "apps" {
+		t.Errorf("unexpected affected resources %+v", analysis.AffectedResources)
+	}
+	if len(analysis.RemediationSteps) != 1 || len(analysis.Evidence) != 1 {
+		t.Errorf("expected remediation steps and evidence, got %+v", analysis)
+	}
+}
+
+func TestParseAnswerJSONText(t *testing.T) {
+	text := "```json\n{\"rootCause\": \"image tag does not exist\", \"category\": \"ImagePull\", \"confidence\": \"Medium\"}\n```"
+	summary, analysis, err := parseAnswer(&ai_provider.Answer{Text: text})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary != "image tag does not exist" || analysis.RootCause != "image tag does not exist" {
+		t.Errorf("expected the root cause as summary, got %q, %+v", summary, analysis)
+	}
+	if analysis.Category != "ImagePull" || analysis.Confidence != v1alpha2.ConfidenceMedium {
+		t.Errorf("expected the unknown category to be kept and a medium confidence, got %+v", analysis)
+	}
+}
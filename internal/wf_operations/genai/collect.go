@@ -0,0 +1,462 @@
+package genai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/utils"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts"
+	rolloutv1alpha1 "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strings"
+)
+
+const (
+	rolloutPodTemplateHashLabel = "rollouts-pod-template-hash"
+	appInstanceLabel            = "app.kubernetes.io/instance"
+)
+
+var (
+	rolloutGroupKind     = schema.GroupKind{Group: rollouts.Group, Kind: rollouts.RolloutKind}
+	analysisRunGroupKind = schema.GroupKind{Group: rollouts.Group, Kind: rollouts.AnalysisRunKind}
+	applicationGroupKind = schema.GroupKind{Group: "argoproj.io", Kind: "Application"}
+	deploymentGroupKind  = schema.GroupKind{Group: appsv1.GroupName, Kind: "Deployment"}
+)
+
+// applicationName returns the Argo CD application the Support belongs to: the one named by the
+// target, the target itself when it is an Application, or the instance label of the Support
+func applicationName(support *v1alpha2.Support) string {
+	if target := support.Spec.Target; target != nil {
+		if target.Application != "" {
+			return target.Application
+		}
+		if target.GroupVersionKind().GroupKind() == applicationGroupKind {
+			return target.Name
+		}
+	}
+	return support.GetLabels()[appInstanceLabel]
+}
+
+func (g *GenAIOperator) buildAITokens(ctx context.Context, app *ai_provider.Application, support *v1alpha2.Support) (string, error) {
+	logger := log.FromContext(ctx)
+
+	var builder strings.Builder
+	builder.WriteString(utils.GetPrompt(g.config, "app-conditions"))
+	if app != nil {
+		builder.WriteString(utils.GetPrompt(g.config, "app-conditions"))
+		if len(app.Status.Conditions) > 0 {
+			for _, condition := range app.Status.Conditions {
+				builder.WriteString(fmt.Sprintf("Condition Message: %s, Status: %s, LastTransitionTime: %s\n", condition.Type, condition.Message, condition.LastTransitionTime))
+			}
+		}
+	}
+
+	// argocd-cm.yaml. Fetch argo app data and analysis the health and app conditions and return the token
+	// 2. Fetch the rollout information and check the health
+	if app != nil {
+		for _, res := range app.Status.Resources {
+			if res.Health != nil && res.Health.Status != ai_provider.HealthStatusHealthy {
+				builder.WriteString(fmt.Sprintf("Resource Name: %s Resource Health: %s  and kubernetes Message: %s", res.Name, res.Health.Status, res.Health.Message))
+			}
+		}
+	}
+
+	var involved map[string]bool
+	var err error
+	if support.Spec.Target != nil {
+		involved, err = g.collectTarget(ctx, &builder, app, support.Spec.Target, support.Namespace)
+	} else {
+		involved, err = g.collectNamespaceRollouts(ctx, &builder, support.Namespace)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	g.collectEvents(ctx, &builder, support.Namespace, involved)
+
+	tokens := builder.String()
+	if limit := utils.GetMaxContextLength(g.config); limit > 0 && len(tokens) > limit {
+		logger.Info("collected context exceeds the configured limit and is truncated", "length", len(tokens), "limit", limit)
+		tokens = tokens[:limit]
+	}
+	return tokens, nil
+}
+
+// collectNamespaceRollouts writes the context of every Rollout in the namespace. It is used for
+// Supports created without spec.target. Events are not scoped, so no involved objects are returned.
+func (g *GenAIOperator) collectNamespaceRollouts(ctx context.Context, builder *strings.Builder, namespace string) (map[string]bool, error) {
+	rolloutLister := rolloutListFromClient(g.dynamicClient)
+	res, err := rolloutLister(ctx, namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range res {
+		if err := g.collectRollout(ctx, builder, r, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	if len(res) > 1 {
+		builder.WriteString(utils.GetPrompt(g.config, "multi-rollout"))
+	}
+	return nil, nil
+}
+
+// collectTarget writes the context of the target and the resources it owns, and returns the
+// names of the objects whose events are relevant to the analysis. Only objects in the namespace
+// of the Support are read, so that a Support cannot expose other namespaces through the
+// cluster-wide access of the controller.
+func (g *GenAIOperator) collectTarget(ctx context.Context, builder *strings.Builder, app *ai_provider.Application, target *v1alpha2.TargetRef, namespace string) (map[string]bool, error) {
+	if target.Namespace != "" && target.Namespace != namespace {
+		return nil, wf_operations.Permanent(fmt.Errorf("target %s is in namespace %s, only targets in the namespace %s of the Support are analyzed", target.Name, target.Namespace, namespace))
+	}
+	involved := map[string]bool{target.Name: true}
+
+	gvk := target.GroupVersionKind()
+	switch gvk.GroupKind() {
+	case applicationGroupKind:
+		// the application itself comes from the Argo CD API, its unhealthy rollouts from the cluster
+		if app == nil {
+			return nil, fmt.Errorf("argo CD application %q could not be fetched", target.Name)
+		}
+		for _, res := range app.Status.Resources {
+			if res.Kind != rollouts.RolloutKind || res.Health == nil || res.Health.Status == ai_provider.HealthStatusHealthy {
+				continue
+			}
+			if res.Namespace != namespace {
+				log.FromContext(ctx).Info("skipping rollout of the application outside the namespace of the Support", "namespace", res.Namespace, "name", res.Name)
+				continue
+			}
+			obj, err := g.getTarget(ctx, rolloutv1alpha1.SchemeGroupVersion.WithKind(rollouts.RolloutKind), namespace, res.Name)
+			if err != nil {
+				return nil, err
+			}
+			rollout := &rolloutv1alpha1.Rollout{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, rollout); err != nil {
+				return nil, err
+			}
+			involved[rollout.Name] = true
+			if err := g.collectRollout(ctx, builder, rollout, involved); err != nil {
+				return nil, err
+			}
+		}
+		return involved, nil
+	}
+
+	obj, err := g.getTarget(ctx, gvk, namespace, target.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch gvk.GroupKind() {
+	case rolloutGroupKind:
+		rollout := &rolloutv1alpha1.Rollout{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, rollout); err != nil {
+			return nil, err
+		}
+		err = g.collectRollout(ctx, builder, rollout, involved)
+	case analysisRunGroupKind:
+		analysisRun := &rolloutv1alpha1.AnalysisRun{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, analysisRun); err != nil {
+			return nil, err
+		}
+		builder.WriteString(utils.GetPrompt(g.config, "analysis-runs"))
+		builder.WriteString(analysisRun.Status.String())
+	case deploymentGroupKind:
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+			return nil, err
+		}
+		err = g.collectDeployment(ctx, builder, deployment, involved)
+	default:
+		status, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "status")
+		if found {
+			data, _ := json.Marshal(status)
+			builder.WriteString(fmt.Sprintf("%s %s status: %s\n", gvk.Kind, target.Name, data))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return involved, nil
+}
+
+// getTarget fetches the object of the given kind through the dynamic client
+func (g *GenAIOperator) getTarget(ctx context.Context, gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	mapping, err := g.k8sClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("could not map target kind %s: %v", gvk, err)
+	}
+	obj, err := g.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get target %s %s/%s: %v", gvk.Kind, namespace, name, err)
+	}
+	return obj, nil
+}
+
+// collectRollout writes the status of the rollout, the latest analysis run it owns and the logs
+// and container statuses of one of its current pods
+func (g *GenAIOperator) collectRollout(ctx context.Context, builder *strings.Builder, r *rolloutv1alpha1.Rollout, involved map[string]bool) error {
+	logger := log.FromContext(ctx)
+
+	builder.WriteString(utils.GetPrompt(g.config, "rollout"))
+	if r.Status.Phase == rolloutv1alpha1.RolloutPhaseHealthy {
+		logger.Info("Rollout seems to be healthy and should not be included in the genai analysis")
+		return nil
+	}
+	builder.WriteString(r.Status.String())
+
+	analysisLister := analysisListFromClient(g.dynamicClient)
+	aRuns, err := analysisLister(ctx, r.Namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var owned []*rolloutv1alpha1.AnalysisRun
+	for _, ar := range aRuns {
+		if metav1.IsControlledBy(ar, r) {
+			owned = append(owned, ar)
+		}
+	}
+	if len(owned) > 0 {
+		// Check the latest revision
+		sort.SliceStable(owned, func(i, j int) bool {
+			return owned[i].CreationTimestamp.After(owned[j].CreationTimestamp.Time)
+		})
+		involved[owned[0].Name] = true
+		builder.WriteString(utils.GetPrompt(g.config, "analysis-runs"))
+		builder.WriteString(owned[0].Status.String())
+	}
+
+	podList, err := getPodsWithLabel(ctx, g.k8sClient, r.Namespace, client.MatchingLabels{rolloutPodTemplateHashLabel: r.Status.CurrentPodHash})
+	if err != nil {
+		return err
+	}
+	g.collectPods(ctx, builder, podList, r.Namespace, involved)
+	return nil
+}
+
+// collectDeployment writes the status of the deployment and the logs and container statuses of one of its pods
+func (g *GenAIOperator) collectDeployment(ctx context.Context, builder *strings.Builder, d *appsv1.Deployment, involved map[string]bool) error {
+	builder.WriteString(fmt.Sprintf("Deployment %s status: %s\n", d.Name, d.Status.String()))
+	if d.Spec.Selector == nil {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	podList, err := getPodsWithLabel(ctx, g.k8sClient, d.Namespace, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return err
+	}
+	g.collectPods(ctx, builder, podList, d.Namespace, involved)
+	return nil
+}
+
+func (g *GenAIOperator) collectPods(ctx context.Context, builder *strings.Builder, podList []string, namespace string, involved map[string]bool) {
+	logger := log.FromContext(ctx)
+
+	for _, pod := range podList {
+		involved[pod] = true
+	}
+	if len(podList) == 0 {
+		builder.WriteString(utils.GetPrompt(g.config, "no-pod-log"))
+		return
+	}
+
+	// it's okay to just check only one pod, since the error is common
+	logs, err := getLogsForPod(ctx, podList[0], namespace, g.kubeClient)
+	if err != nil {
+		if strings.Contains(err.Error(), "no error found in logs") {
+			builder.WriteString(utils.GetPrompt(g.config, "no-pod-error-log"))
+		} else {
+			logger.Error(err, "failed to process the pod logs")
+		}
+	} else {
+		builder.WriteString(utils.GetPrompt(g.config, "logs"))
+		builder.WriteString(logs)
+	}
+
+	podStatus, err := getPodStatus(ctx, podList[0], namespace, g.kubeClient)
+	if err != nil {
+		logger.Error(err, "failed to process the pod status")
+		return
+	}
+	builder.WriteString(utils.GetPrompt(g.config, "podContainerStatus"))
+	for _, containerStatus := range podStatus.ContainerStatuses {
+		builder.WriteString(fmt.Sprintf("Container Name: %s,started: %v, State: %s, Ready: %t, Restart Count: %d\n",
+			containerStatus.Name, containerStatus.Started, containerStatus.State.String(), containerStatus.Ready, containerStatus.RestartCount))
+	}
+	builder.WriteString(utils.GetPrompt(g.config, "podInitContainerStatus"))
+	for _, containerStatus := range podStatus.InitContainerStatuses {
+		builder.WriteString(fmt.Sprintf("Container Name: %s,started: %v, State: %s, Ready: %t, Restart Count: %d\n",
+			containerStatus.Name, containerStatus.Started, containerStatus.State.String(), containerStatus.Ready, containerStatus.RestartCount))
+	}
+}
+
+// collectEvents writes the warning and failure events of the namespace. When involved is not nil
+// only events about the listed objects are written.
+func (g *GenAIOperator) collectEvents(ctx context.Context, builder *strings.Builder, namespace string, involved map[string]bool) {
+	logger := log.FromContext(ctx)
+
+	logger.Info("start collecting pod data")
+	var eventList v1.EventList
+	err := g.k8sClient.List(ctx, &eventList, client.InNamespace(namespace))
+	if err != nil {
+		logger.Error(err, "Failed to fetch events for namespace", "namespace", namespace)
+		return
+	}
+
+	builder.WriteString(utils.GetPrompt(g.config, "event"))
+	for _, event := range eventList.Items {
+		if involved != nil && !involved[event.InvolvedObject.Name] {
+			continue
+		}
+		if event.Type == v1.EventTypeWarning || strings.Contains(event.Message, "Failed") {
+			logger.Info("Failed or Warn Events Detected:", "Reason", event.Reason, "Message", event.Message)
+			builder.WriteString(event.String())
+		}
+	}
+}
+
+func genericListFromClient(c dynamic.DynamicClient, gvr schema.GroupVersionResource) func(context.Context, string, metav1.ListOptions) ([]*unstructured.Unstructured, error) {
+	return func(ctx context.Context, namespace string, options metav1.ListOptions) ([]*unstructured.Unstructured, error) {
+		res, err := c.Resource(gvr).Namespace(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		var resourceList []*unstructured.Unstructured
+		for i := range res.Items {
+			resourceList = append(resourceList, &res.Items[i])
+		}
+		return resourceList, nil
+	}
+}
+
+type rolloutListFunc func(ctx context.Context, namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.Rollout, error)
+type analysisListFunc func(ctx context.Context, namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.AnalysisRun, error)
+
+func rolloutListFromClient(c dynamic.DynamicClient) rolloutListFunc {
+	genericLister := genericListFromClient(c, rolloutv1alpha1.SchemeGroupVersion.WithResource("rollouts"))
+	return func(ctx context.Context, namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.Rollout, error) {
+		unstructuredList, err := genericLister(ctx, namespace, options)
+		if err != nil {
+			return nil, err
+		}
+		var rolloutList []*rolloutv1alpha1.Rollout
+		for _, unstructuredRollout := range unstructuredList {
+			rollout := &rolloutv1alpha1.Rollout{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredRollout.Object, rollout)
+			if err != nil {
+				return nil, err
+			}
+			rolloutList = append(rolloutList, rollout)
+		}
+		return rolloutList, nil
+	}
+}
+
+func analysisListFromClient(c dynamic.DynamicClient) analysisListFunc {
+	genericLister := genericListFromClient(c, rolloutv1alpha1.SchemeGroupVersion.WithResource("analysisruns"))
+	return func(ctx context.Context, namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.AnalysisRun, error) {
+		unstructuredList, err := genericLister(ctx, namespace, options)
+		if err != nil {
+			return nil, err
+		}
+		var analysisRunList []*rolloutv1alpha1.AnalysisRun
+		for _, unstructuredAnalysisRun := range unstructuredList {
+			analysisRun := &rolloutv1alpha1.AnalysisRun{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredAnalysisRun.Object, analysisRun)
+			if err != nil {
+				return nil, err
+			}
+			analysisRunList = append(analysisRunList, analysisRun)
+		}
+		return analysisRunList, nil
+	}
+}
+
+func getPodsWithLabel(ctx context.Context, K8sClient client.Client, namespace string, selector client.ListOption) ([]string, error) {
+	podList := &v1.PodList{}
+
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		selector,
+	}
+	if err := K8sClient.List(ctx, podList, listOpts...); err != nil {
+		return nil, err
+	}
+	var podNames []string
+	for _, pod := range podList.Items {
+		podNames = append(podNames, pod.Name)
+	}
+	return podNames, nil
+}
+
+func getLogsForPod(ctx context.Context, podName, namespace string, kubeClient kubernetes.Interface) (string, error) {
+	// Fetch logs from the pod
+	podLogOpts := v1.PodLogOptions{}
+	req := kubeClient.CoreV1().Pods(namespace).GetLogs(podName, &podLogOpts)
+
+	podLogs, err := req.Stream(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch logs: %v", err)
+	}
+	defer podLogs.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err = io.Copy(buf, podLogs); err != nil {
+		return "", fmt.Errorf("could not read logs: %v", err)
+	}
+
+	logs := buf.String()
+	lines := strings.Split(logs, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "error") {
+			start := maxLine(0, i-5)
+			end := minLine(len(lines), i+6)
+			return strings.Join(lines[start:end], "\n"), nil
+		}
+	}
+
+	return "", fmt.Errorf("no error found in logs")
+}
+
+func getPodStatus(ctx context.Context, podName, namespace string, kubeClient kubernetes.Interface) (*v1.PodStatus, error) {
+	pod, err := kubeClient.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get pod: %v", err)
+	}
+
+	return &pod.Status, nil
+}
+
+func minLine(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxLine(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -1 +1,84 @@
 package genai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestApplicationName(t *testing.T) {
+	support := &v1alpha2.Support{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{appInstanceLabel: "from-label"}},
+	}
+	if name := applicationName(support); name != "from-label" {
+		t.Errorf("expected the instance label without target, got %q", name)
+	}
+
+	support.Spec.Target = &v1alpha2.TargetRef{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout", Name: "rollout", Application: "from-target"}
+	if name := applicationName(support); name != "from-target" {
+		t.Errorf("expected the application of the target, got %q", name)
+	}
+
+	support.Spec.Target = &v1alpha2.TargetRef{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application", Name: "guestbook"}
+	if name := applicationName(support); name != "guestbook" {
+		t.Errorf("expected the target application itself, got %q", name)
+	}
+}
+
+func TestNewGenAIOperationsConfigurationError(t *testing.T) {
+	k8sClient := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+	argoCD := v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "argocd"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type: v1alpha2.AuthProviderTypeArgoCD,
+			Auth: v1alpha2.Auth{BaseURL: "https://argocd.example.com"},
+		},
+	}
+	openAI := v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "openai"},
+		Spec: v1alpha2.AuthProviderSpec{
+			Type:   v1alpha2.AuthProviderTypeOpenAI,
+			Auth:   v1alpha2.Auth{BaseURL: "https://api.openai.com/v1"},
+			OpenAI: &v1alpha2.LLMSettings{Model: "gpt-4o-mini", Temperature: "warm"},
+		},
+	}
+
+	for name, authProviders := range map[string][]v1alpha2.AuthProvider{
+		"missing llm provider":  {argoCD},
+		"invalid temperature":   {openAI, argoCD},
+		"missing gitops server": {{ObjectMeta: openAI.ObjectMeta, Spec: v1alpha2.AuthProviderSpec{Type: v1alpha2.AuthProviderTypeOpenAI, Auth: openAI.Spec.Auth, OpenAI: &v1alpha2.LLMSettings{Model: "gpt-4o-mini"}}}},
+	} {
+		_, err := NewGenAIOperations(context.Background(), wf_operations.ExecutorParams{
+			Dependencies:  wf_operations.Dependencies{Client: k8sClient, ProviderClients: ai_provider.NewClientPool()},
+			Support:       &v1alpha2.Support{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "support"}},
+			AuthProviders: authProviders,
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if wf_operations.IsRetryable(err) {
+			t.Errorf("%s: expected the configuration error to fail the first attempt for good, got %v", name, err)
+		}
+	}
+}
+
+func TestCollectTargetInOtherNamespace(t *testing.T) {
+	g := &GenAIOperator{}
+	target := &v1alpha2.TargetRef{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout", Name: "rollout", Namespace: "kube-system"}
+	var builder strings.Builder
+	_, err := g.collectTarget(context.Background(), &builder, nil, target, "tenant-a")
+	if err == nil || wf_operations.IsRetryable(err) {
+		t.Errorf("expected a target outside the namespace of the Support to be refused for good, got %v", err)
+	}
+	if builder.Len() != 0 {
+		t.Errorf("expected nothing to be collected, got %q", builder.String())
+	}
+}
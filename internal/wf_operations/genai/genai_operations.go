@@ -1,399 +1,210 @@
 package genai
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/argoproj-labs/argo-support/api/v1alpha1"
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
 	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
 	"github.com/argoproj-labs/argo-support/internal/utils"
 	"github.com/argoproj-labs/argo-support/internal/wf_operations"
-	rolloutv1alpha1 "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
-	"io"
-	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"strings"
 )
 
 const (
-	genAIEndPointSuffix  = "/analyze"
-	argoV1ResAPI         = "/api/v1/applications"
-	responseType         = "/analyses"
-	argocdEndPointSuffix = "/api/v1/applications/"
-	rolloutRevision      = "rollout.argoproj.io/revision"
+	// WorkflowName is the name a Support uses to select the gen AI workflow
+	WorkflowName = "gen-ai"
+
+	responseType = "/analyses"
 )
 
 type GenAIOperator struct {
 	k8sClient     client.Client
 	genAIClient   ai_provider.HttpClient
+	llm           ai_provider.Provider
 	dynamicClient dynamic.DynamicClient
 	argoCDClient  ai_provider.HttpClient
 	kubeClient    kubernetes.Interface
-	configMap     *v1.ConfigMap
+	config        map[string]string
+	timeouts      wf_operations.StageTimeouts
+	// workflow is the name of the workflow the operator runs, which names its results
+	workflow string
 }
 
 var (
 	_ wf_operations.Executor = &GenAIOperator{}
 )
 
-// NewGenAIOperations create GenAIOperation with the k8s API
-func NewGenAIOperations(ctx context.Context, k8sClient client.Client, dynamicClient dynamic.DynamicClient, kubeClient kubernetes.Interface, wf *v1alpha1.Workflow, namespace string) (*GenAIOperator, error) {
-	//logger := log.FromContext(ctx)
-	authProviders, err := utils.GetAuthProviders(ctx, k8sClient, &wf.Ref, namespace)
+func init() {
+	wf_operations.RegisterExecutor(WorkflowName, NewGenAIOperations)
+}
+
+// NewGenAIOperations create GenAIOperation with the k8s API, the resolved AuthProviders, the
+// effective configuration of the workflow and the deadlines of the stages of an attempt
+func NewGenAIOperations(ctx context.Context, params wf_operations.ExecutorParams) (wf_operations.Executor, error) {
+	namespace := params.Support.Namespace
+	genClient, err := params.ProviderClients.ClientForRole(ctx, params.Client, &params.AuthProviders, v1alpha2.ProviderRoleLLM, namespace)
 	if err != nil {
-		return nil, err
+		return nil, wf_operations.ProviderClientError(err)
 	}
 
-	cm, err := utils.GetConfigMapRef(ctx, k8sClient, &wf.ConfigMapRef, namespace)
+	argoCDClient, err := params.ProviderClients.ClientForRole(ctx, params.Client, &params.AuthProviders, v1alpha2.ProviderRoleGitOps, namespace)
 	if err != nil {
-		return nil, err
+		return nil, wf_operations.ProviderClientError(err)
 	}
 
-	genClient, err := ai_provider.GetGenAIClientWithSecret(ctx, k8sClient, authProviders, namespace)
-	if err != nil {
-		return nil, err
+	workflow := WorkflowName
+	if params.Workflow != nil {
+		workflow = params.Workflow.Name
 	}
 
-	argoCDClient, err := ai_provider.GetArgoCDClienWithSecret(ctx, k8sClient, authProviders, namespace)
-	if err != nil {
-		return nil, err
+	// providers of slower models bring their own deadline for the analysis
+	timeouts := params.StageTimeouts
+	if genClient.Timeout > 0 {
+		timeouts.LLM = genClient.Timeout
 	}
 
 	return &GenAIOperator{
-		k8sClient:     k8sClient,
+		k8sClient:     params.Client,
 		genAIClient:   *genClient,
+		llm:           ai_provider.NewProvider(genClient),
 		argoCDClient:  *argoCDClient,
-		dynamicClient: dynamicClient,
-		kubeClient:    kubeClient,
-		configMap:     cm,
+		dynamicClient: params.DynamicClient,
+		kubeClient:    params.KubeClient,
+		config:        params.Config,
+		timeouts:      timeouts,
+		workflow:      workflow,
 	}, nil
 }
 
-func (g *GenAIOperator) Process(ctx context.Context, obj metav1.Object) (*v1alpha1.Support, error) {
+func (g *GenAIOperator) Process(ctx context.Context, obj metav1.Object) (*v1alpha2.Result, error) {
 	logger := log.FromContext(ctx)
 
-	labels := obj.GetLabels()
-	fullUrl := fmt.Sprint(g.argoCDClient.BaseURL + argocdEndPointSuffix + labels["app.kubernetes.io/instance"])
-
-	app, err := g.argoCDClient.GetRequest(fullUrl, nil)
-
-	t, _ := g.buildAITokens(ctx, app, obj)
-
-	//{\n          \"failures\": [\n            {\n              \"context\":  }\n    t      ]\n        }"
-	failures := ai_provider.Failures{
-		Failures: []ai_provider.Failure{
-			{Context: t},
-		},
-	}
-
-	// Marshal the struct to JSON
-	tokens, _ := json.Marshal(failures)
-	logger.Info("tokens to be processed", "tokens length", len(tokens))
-
-	res, err := g.genAIClient.PostRequest(ctx, string(tokens), genAIEndPointSuffix)
-	if err != nil {
-		return nil, fmt.Errorf("failed to post request: %v", err)
-	}
-
-	summary, ok := res.(map[string]interface{})
+	argoOpsobj, ok := obj.(*v1alpha2.Support)
 	if !ok {
-		return nil, fmt.Errorf("type assertion to map[string]interface{} failed")
+		return nil, fmt.Errorf("type assertion to *v1alpha2.ArgoSupportSpec failed")
 	}
 
-	value, exists := summary["analyses"]
-	if !exists {
-		return nil, fmt.Errorf("key 'analyses' not found in the result")
-	}
-
-	analysesSlice, ok := value.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("type assertion for 'analyses' as []interface{} failed")
-	}
-
-	// Assuming that each element in analysesSlice is a map[string]interface{} that contains an "analysis" key
-	var genSummary string
-	for _, analysis := range analysesSlice {
-		analysisMap, ok := analysis.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("type assertion for individual analysis failed")
-		}
-		genSummary, ok = analysisMap["analysis"].(string)
-		if !ok {
-			return nil, fmt.Errorf("type assertion for 'analysis' as string failed")
-		}
-		break
-	}
-
-	argoOpsobj, ok := obj.(*v1alpha1.Support)
-	if !ok {
-		return nil, fmt.Errorf("type assertion to *v1alpha1.ArgoSupportSpec failed")
-	}
-	var slackSupport string
-	slackSupport, _ = g.configMap.Data["slackSupport"]
-
-	help := v1alpha1.Help{
-		SlackChannel: slackSupport,
-	}
-	now := metav1.Now()
-	epochTime := now.Unix()
-
-	argoOpsobj.Status = v1alpha1.SupportStatus{
-		Results: append(argoOpsobj.Status.Results, v1alpha1.Result{
-			Name: fmt.Sprintf("%s-%d", argoOpsobj.Spec.Workflows[0].Name, epochTime),
-			Summary: v1alpha1.Summary{
-				MainSummary: genSummary,
-			},
-			Help:       help,
-			FinishedAt: &now,
-			Message:    "Gen AI request completed",
-		}),
-		Phase: v1alpha1.ArgoSupportPhaseCompleted,
-	}
-	return argoOpsobj, nil
-}
-
-func (g *GenAIOperator) buildAITokens(ctx context.Context, app *ai_provider.Application, o metav1.Object) (string, error) {
-	logger := log.FromContext(ctx)
-
-	var builder strings.Builder
-	builder.WriteString(utils.GetInlinePrompt("app-conditions", ""))
-	if app != nil {
-		builder.WriteString(utils.GetInlinePrompt("app-conditions", ""))
-		if len(app.Status.Conditions) > 0 {
-			for _, condition := range app.Status.Conditions {
-				builder.WriteString(fmt.Sprintf("Condition Message: %s, Status: %s, LastTransitionTime: %s\n", condition.Type, condition.Message, condition.LastTransitionTime))
-			}
-		}
-	}
-
-	// argocd-cm.yaml. Fetch argo app data and analysis the health and app conditions and return the token
-	// 2. Fetch the rollout information and check the health
-	if app != nil {
-		for _, res := range app.Status.Resources {
-			if res.Health != nil && res.Health.Status != ai_provider.HealthStatusHealthy {
-				builder.WriteString(fmt.Sprintf("Resource Name: %s Resource Health: %s  and kubernetes Message: %s", res.Name, res.Health.Status, res.Health.Message))
-			}
-		}
+	t, err := g.collectContext(ctx, g.fetchApplication(ctx, argoOpsobj), argoOpsobj)
+	if err != nil {
+		return nil, err
 	}
 
-	rolloutLister := rolloutListFromClient(g.dynamicClient)
-	res, err := rolloutLister(o.GetNamespace(), metav1.ListOptions{})
-
-	var podList []string
-	var aRuns []*rolloutv1alpha1.AnalysisRun
+	logger.Info("context to be analyzed", "context length", len(t), "provider", g.genAIClient.Type)
 
+	var authorizationHeader string
+	err = wf_operations.RunStage(ctx, g.timeouts, wf_operations.StageIdentity, func(ctx context.Context) error {
+		var err error
+		authorizationHeader, err = g.llm.AuthorizationHeader(ctx)
+		return err
+	})
 	if err != nil {
-		return "", err
-	} else {
-		for _, r := range res {
-			builder.WriteString(utils.GetInlinePrompt("rollout", r.Name))
-			if r.Status.Phase != rolloutv1alpha1.RolloutPhaseHealthy {
-				if rollout, ok := utils.StripTheKeys(r).(*rolloutv1alpha1.Rollout); ok {
-					pods, _ := getPodsWithLabel(g.k8sClient, r.Status.CurrentPodHash)
-					podList = append(podList, pods...)
-					analysisLister := analysisListFromClient(g.dynamicClient)
-					aRuns, err = analysisLister(o.GetNamespace(), metav1.ListOptions{})
-					for i, ar := range aRuns {
-						if o.GetAnnotations()[rolloutRevision] == ar.Annotations[rolloutRevision] {
-							aRuns = append(aRuns[:i], aRuns[i+1:]...)
-						}
-					}
-					builder.WriteString(rollout.Status.String())
-				}
-			} else {
-				logger.Info("Rollout seems to be healthy and should not be included in the genai analysis")
-			}
-			if aRuns != nil && len(aRuns) > 1 {
-				builder.WriteString(utils.GetInlinePrompt("analysis-runs", ""))
-				// Check the latest revision
-				builder.WriteString(aRuns[0].Status.String())
-			}
-			if podList != nil && len(podList) >= 1 {
-				// it's okay to just check only one pod, since the error is common
-				logs, err := getLogsForPod(podList[0], r.Namespace, g.kubeClient)
-				if err != nil {
-					if strings.Contains(err.Error(), "no error found in logs") {
-						builder.WriteString(utils.GetInlinePrompt("no-pod-error-log", ""))
-					} else if strings.Contains(err.Error(), "could not") {
-						logger.Error(err, "failed to process the pod logs")
-					} else {
-						builder.WriteString(utils.GetInlinePrompt("pod", ""))
-						builder.WriteString(logs)
-					}
-				}
+		argoOpsobj.SetCondition(v1alpha2.SupportConditionProviderReachable, metav1.ConditionFalse, v1alpha2.SupportReasonRequestFailed, err.Error())
+		return nil, fmt.Errorf("failed to authorize: %w", err)
+	}
+
+	var answer *ai_provider.Answer
+	err = wf_operations.RunStage(ctx, g.timeouts, wf_operations.StageLLM, func(ctx context.Context) error {
+		var err error
+		answer, err = g.llm.Analyze(ctx, authorizationHeader, &ai_provider.AnalysisRequest{
+			Instructions: analysisInstructions(g.config),
+			Context:      t,
+		})
+		return err
+	})
+	var invalid *ai_provider.InvalidResponseError
+	if err != nil && !errors.As(err, &invalid) {
+		argoOpsobj.SetCondition(v1alpha2.SupportConditionProviderReachable, metav1.ConditionFalse, v1alpha2.SupportReasonRequestFailed, err.Error())
+		return nil, fmt.Errorf("failed to post request: %w", err)
+	}
+	argoOpsobj.SetCondition(v1alpha2.SupportConditionProviderReachable, metav1.ConditionTrue, v1alpha2.SupportReasonReachable,
+		fmt.Sprintf("%s provider at %s answered", g.genAIClient.Type, g.genAIClient.BaseURL))
 
-			} else {
-				builder.WriteString(utils.GetInlinePrompt("no-pod-log", ""))
-			}
-			if podList != nil && len(podList) >= 1 {
-				podStatus, err := getPodStatus(podList[0], r.Namespace, g.kubeClient)
-				if err != nil {
-					logger.Error(err, "failed to process the pod status")
-				}
-				builder.WriteString(utils.GetInlinePrompt("podContainerStatus", ""))
-				for _, containerStatus := range podStatus.ContainerStatuses {
-					builder.WriteString(fmt.Sprintf("Container Name: %s,started: %b, State: %s, Ready: %t, Restart Count: %d\n",
-						containerStatus.Name, containerStatus.Started, containerStatus.State, containerStatus.Ready, containerStatus.RestartCount))
-				}
-				builder.WriteString(utils.GetInlinePrompt("podInitContainerStatus", ""))
-				for _, containerStatus := range podStatus.InitContainerStatuses {
-					builder.WriteString(fmt.Sprintf("Container Name: %s,started: %b, State: %s, Ready: %t, Restart Count: %d\n",
-						containerStatus.Name, containerStatus.Started, containerStatus.State, containerStatus.Ready, containerStatus.RestartCount))
-				}
-			}
-
-		}
-		if len(res) > 1 {
-			builder.WriteString(utils.GetInlinePrompt("multi-rollout", ""))
-		}
+	var genSummary string
+	var analysis *v1alpha2.Analysis
+	if err == nil {
+		genSummary, analysis, err = parseAnswer(answer)
 	}
-
-	logger.Info("start collecting pod data")
-	var eventList v1.EventList
-	err = g.k8sClient.List(ctx, &eventList, client.InNamespace(o.GetNamespace()))
-
 	if err != nil {
-		logger.Error(err, "Failed to fetch events for namespace %s", o.GetNamespace())
-	} else {
-		for _, event := range eventList.Items {
-			if event.Message == "Warning" || strings.Contains(event.Message, "Failed") {
-				logger.Info("Failed or Warn Events Detected:", "Reason", event.Reason, "Message", event.Message)
-				builder.WriteString(event.String())
-			}
-		}
+		argoOpsobj.SetCondition(v1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, v1alpha2.SupportReasonInvalidResponse, err.Error())
+		return nil, err
 	}
 
-	return builder.String(), nil
-}
-
-func genericListFromClient(c dynamic.DynamicClient, gvr schema.GroupVersionResource) func(string, metav1.ListOptions) ([]*unstructured.Unstructured, error) {
-	return func(namespace string, options metav1.ListOptions) ([]*unstructured.Unstructured, error) {
-		res, err := c.Resource(gvr).Namespace(namespace).List(context.Background(), options)
-		if err != nil {
-			return nil, err
-		}
-		var resourceList []*unstructured.Unstructured
-		for i := range res.Items {
-			resourceList = append(resourceList, &res.Items[i])
-		}
-		return resourceList, nil
-	}
-}
-
-type rolloutListFunc func(namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.Rollout, error)
-type analysisListFunc func(namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.AnalysisRun, error)
-
-func rolloutListFromClient(c dynamic.DynamicClient) rolloutListFunc {
-	genericLister := genericListFromClient(c, v1alpha1.SchemeGroupVersion.WithResource("rollouts"))
-	return func(namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.Rollout, error) {
-		unstructuredList, err := genericLister(namespace, options)
-		if err != nil {
-			return nil, err
-		}
-		var rolloutList []*rolloutv1alpha1.Rollout
-		for _, unstructuredRollout := range unstructuredList {
-			rollout := &rolloutv1alpha1.Rollout{}
-			err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredRollout.Object, rollout)
-			if err != nil {
-				return nil, err
-			}
-			rolloutList = append(rolloutList, rollout)
-		}
-		return rolloutList, nil
-	}
-}
+	help := utils.GetHelp(g.config)
+	now := metav1.Now()
 
-func analysisListFromClient(c dynamic.DynamicClient) analysisListFunc {
-	genericLister := genericListFromClient(c, v1alpha1.SchemeGroupVersion.WithResource("analysisruns"))
-	return func(namespace string, options metav1.ListOptions) ([]*rolloutv1alpha1.AnalysisRun, error) {
-		unstructuredList, err := genericLister(namespace, options)
-		if err != nil {
-			return nil, err
-		}
-		var analysisRunList []*rolloutv1alpha1.AnalysisRun
-		for _, unstructuredAnalysisRun := range unstructuredList {
-			analysisRun := &rolloutv1alpha1.AnalysisRun{}
-			err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredAnalysisRun.Object, analysisRun)
-			if err != nil {
-				return nil, err
-			}
-			analysisRunList = append(analysisRunList, analysisRun)
-		}
-		return analysisRunList, nil
-	}
+	message := fmt.Sprintf("analysis of %s completed", targetDescription(argoOpsobj))
+	argoOpsobj.SetCondition(v1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionTrue, v1alpha2.SupportReasonSucceeded, message)
+	return &v1alpha2.Result{
+		Name:       wf_operations.ResultName(argoOpsobj, g.workflow, now.Time),
+		Summary:    genSummary,
+		Analysis:   analysis,
+		Help:       help,
+		FinishedAt: &now,
+		Message:    message,
+	}, nil
 }
 
-func getPodsWithLabel(K8sClient client.Client, label string) ([]string, error) {
-	podList := &v1.PodList{}
-
-	// Create a ListOption with LabelSelector
-	listOpts := []client.ListOption{
-		client.MatchingLabels{"rollouts-pod-template-hash": label},
-	}
-	if err := K8sClient.List(context.TODO(), podList, listOpts...); err != nil {
-		return nil, err
-	}
-	var podNames []string
-	for _, pod := range podList.Items {
-		podNames = append(podNames, pod.Name)
-	}
-	return podNames, nil
+// CollectContext collects the context of the Support the way the gen-ai workflow does, for executors
+// handing it to another service. The Argo CD Application is included when the workflow references an
+// argocd AuthProvider. The ContextCollected condition of the Support is set along the way.
+func CollectContext(ctx context.Context, params wf_operations.ExecutorParams) (string, error) {
+	g := &GenAIOperator{
+		k8sClient:     params.Client,
+		dynamicClient: params.DynamicClient,
+		kubeClient:    params.KubeClient,
+		config:        params.Config,
+		timeouts:      params.StageTimeouts,
+	}
+	var app *ai_provider.Application
+	argoCDClient, err := params.ProviderClients.ClientForRole(ctx, params.Client, &params.AuthProviders, v1alpha2.ProviderRoleGitOps, params.Support.Namespace)
+	if err == nil {
+		g.argoCDClient = *argoCDClient
+		app = g.fetchApplication(ctx, params.Support)
+	}
+	return g.collectContext(ctx, app, params.Support)
 }
-func getLogsForPod(podName, namespace string, kubeClient kubernetes.Interface) (string, error) {
-	// Fetch logs from the pod
-	podLogOpts := v1.PodLogOptions{}
-	req := kubeClient.CoreV1().Pods(namespace).GetLogs(podName, &podLogOpts)
-
-	podLogs, err := req.Stream(context.Background())
-	if err != nil {
-		return "", fmt.Errorf("could not fetch logs: %v", err)
-	}
-	defer podLogs.Close()
 
-	buf := new(bytes.Buffer)
-	if _, err = io.Copy(buf, podLogs); err != nil {
-		return "", fmt.Errorf("could not read logs: %v", err)
-	}
-
-	logs := buf.String()
-	lines := strings.Split(logs, "\n")
-	for i, line := range lines {
-		if strings.Contains(line, "error") {
-			start := maxLine(0, i-5)
-			end := minLine(len(lines), i+6)
-			return strings.Join(lines[start:end], "\n"), nil
-		}
-	}
-
-	return "", fmt.Errorf("no error found in logs")
-}
-func getPodStatus(podName, namespace string, kubeClient kubernetes.Interface) (*v1.PodStatus, error) {
-	pod, err := kubeClient.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+// fetchApplication returns the Argo CD Application of the Support, or nil when it cannot be fetched
+func (g *GenAIOperator) fetchApplication(ctx context.Context, support *v1alpha2.Support) *ai_provider.Application {
+	var app *ai_provider.Application
+	err := wf_operations.RunStage(ctx, g.timeouts, wf_operations.StageArgoCD, func(ctx context.Context) error {
+		var err error
+		app, err = g.argoCDClient.GetApplication(ctx, applicationName(support))
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("could not get pod: %v", err)
+		log.FromContext(ctx).Info("failed to fetch the Argo CD application, continuing without it", "error", err.Error())
+		return nil
 	}
-
-	return &pod.Status, nil
+	return app
 }
 
-func minLine(a, b int) int {
-	if a < b {
-		return a
+// collectContext runs the collection stage and records its outcome in the conditions of the Support
+func (g *GenAIOperator) collectContext(ctx context.Context, app *ai_provider.Application, support *v1alpha2.Support) (string, error) {
+	var t string
+	err := wf_operations.RunStage(ctx, g.timeouts, wf_operations.StageCollection, func(ctx context.Context) error {
+		var err error
+		t, err = g.buildAITokens(ctx, app, support)
+		return err
+	})
+	if err != nil {
+		support.SetCondition(v1alpha2.SupportConditionContextCollected, metav1.ConditionFalse, v1alpha2.SupportReasonCollectionFailed, err.Error())
+		return "", fmt.Errorf("failed to collect context: %w", err)
 	}
-	return b
+	support.SetCondition(v1alpha2.SupportConditionContextCollected, metav1.ConditionTrue, v1alpha2.SupportReasonCollected,
+		fmt.Sprintf("collected %d characters of context for %s", len(t), targetDescription(support)))
+	return t, nil
 }
 
-func maxLine(a, b int) int {
-	if a > b {
-		return a
+// targetDescription names what the Support analyzes for status messages
+func targetDescription(support *v1alpha2.Support) string {
+	if target := support.Spec.Target; target != nil {
+		return fmt.Sprintf("%s %s", target.Kind, target.Name)
 	}
-	return b
+	return fmt.Sprintf("rollouts in namespace %s", support.Namespace)
 }
@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	protocol "github.com/argoproj-labs/argo-support/pkg/plugin"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const testToken = "plugin-token"
+
+// standIn serves the plugin protocol like an out-of-process plugin would
+func standIn(t *testing.T, versions []string, analyze protocol.AnalyzeFunc) *httptest.Server {
+	server := httptest.NewServer(&protocol.Handler{
+		Manifest: protocol.Manifest{Name: "stand-in", ProtocolVersions: versions, Workflows: []string{"triage"}},
+		Token:    testToken,
+		Analyze:  analyze,
+	})
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestExecutor(baseURL, token string, timeout time.Duration) (*PluginExecutor, *v1alpha2.Support) {
+	support := &v1alpha2.Support{
+		ObjectMeta: metav1.ObjectMeta{Name: "support", Namespace: "team-a"},
+		Spec: v1alpha2.SupportSpec{
+			Target: &v1alpha2.TargetRef{Group: "apps", Version: "v1", Kind: "Deployment", Name: "guestbook"},
+		},
+	}
+	executor := &PluginExecutor{
+		client: ai_provider.HttpClient{Type: v1alpha2.AuthProviderTypePlugin, BaseURL: baseURL, AppSecret: token},
+		params: wf_operations.ExecutorParams{
+			Dependencies: wf_operations.Dependencies{StageTimeouts: wf_operations.StageTimeouts{Plugin: timeout}},
+			Workflow:     &v1alpha2.Workflow{Name: "triage"},
+			Support:      support,
+			Config:       map[string]string{"help": "ask in #triage"},
+			Inputs:       map[string]v1alpha2.Result{"gen-ai": {Name: "gen-ai-1", Summary: "image pull failure"}},
+		},
+		collect: func(ctx context.Context, params wf_operations.ExecutorParams) (string, error) {
+			return "pods are crash looping", nil
+		},
+	}
+	return executor, support
+}
+
+func TestPluginConformance(t *testing.T) {
+	var got *protocol.AnalyzeRequest
+	server := standIn(t, []string{"v2", protocol.ProtocolV1}, func(ctx context.Context, req *protocol.AnalyzeRequest) (*v1alpha2.Result, error) {
+		got = req
+		return &v1alpha2.Result{Name: "triage-1", Summary: "crash loop", Analysis: &v1alpha2.Analysis{RootCause: "the image is missing"}}, nil
+	})
+	executor, support := newTestExecutor(server.URL, testToken, time.Minute)
+
+	result, err := executor.Process(context.Background(), support)
+	if err != nil {
+		t.Fatalf("expected the plugin to succeed, got %v", err)
+	}
+	if !strings.HasPrefix(result.Name, "triage-") || result.Summary != "crash loop" || result.FinishedAt == nil {
+		t.Errorf("expected the result of the plugin with a generated name and a finish time, got %+v", result)
+	}
+	if got.APIVersion != protocol.ProtocolV1 {
+		t.Errorf("expected the common version %s to be negotiated, got %q", protocol.ProtocolV1, got.APIVersion)
+	}
+	if got.Context != "pods are crash looping" || got.Target == nil || got.Target.Name != "guestbook" || got.Config["help"] == "" {
+		t.Errorf("expected the request to carry the context, target and config, got %+v", got)
+	}
+	if got.Inputs["gen-ai"].Summary != "image pull failure" {
+		t.Errorf("expected the request to carry the results of the dependencies, got %+v", got.Inputs)
+	}
+	if got.Support.Name != "support" || got.Workflow.Name != "triage" {
+		t.Errorf("expected the request to carry the Support and workflow, got %+v", got)
+	}
+	if condition := meta.FindStatusCondition(support.Status.Conditions, v1alpha2.SupportConditionAnalysisSucceeded); condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Errorf("expected AnalysisSucceeded to be true, got %+v", condition)
+	}
+}
+
+func TestPluginResultName(t *testing.T) {
+	server := standIn(t, []string{protocol.ProtocolV1}, func(ctx context.Context, req *protocol.AnalyzeRequest) (*v1alpha2.Result, error) {
+		return &v1alpha2.Result{Name: "Not A/Valid_Name", Phase: v1alpha2.ArgoSupportPhaseCancelled, Summary: "crash loop"}, nil
+	})
+	executor, support := newTestExecutor(server.URL, testToken, time.Minute)
+	support.Status.Workflows = []v1alpha2.WorkflowStatus{{Name: "triage", RunID: "run", Attempts: 2}}
+
+	result, err := executor.Process(context.Background(), support)
+	if err != nil {
+		t.Fatalf("expected the plugin to succeed, got %v", err)
+	}
+	if want := wf_operations.ResultName(support, "triage", result.FinishedAt.Time); result.Name != want {
+		t.Errorf("expected the name of the plugin to be replaced by %q, got %q", want, result.Name)
+	}
+	if errs := validation.IsDNS1123Subdomain(result.Name); len(errs) > 0 {
+		t.Errorf("expected %q to be a valid object name, got %v", result.Name, errs)
+	}
+	if result.Phase != "" {
+		t.Errorf("expected the phase of the plugin to be dropped, got %q", result.Phase)
+	}
+}
+
+func TestPluginVersionMismatch(t *testing.T) {
+	server := standIn(t, []string{"v2"}, func(ctx context.Context, req *protocol.AnalyzeRequest) (*v1alpha2.Result, error) {
+		t.Error("expected no analyze request without a common version")
+		return nil, nil
+	})
+	executor, support := newTestExecutor(server.URL, testToken, time.Minute)
+
+	_, err := executor.Process(context.Background(), support)
+	if err == nil || wf_operations.IsRetryable(err) {
+		t.Errorf("expected a permanent error without a common version, got %v", err)
+	}
+}
+
+func TestPluginAuthentication(t *testing.T) {
+	server := standIn(t, []string{protocol.ProtocolV1}, func(ctx context.Context, req *protocol.AnalyzeRequest) (*v1alpha2.Result, error) {
+		return &v1alpha2.Result{}, nil
+	})
+	executor, support := newTestExecutor(server.URL, "wrong-token", time.Minute)
+
+	_, err := executor.Process(context.Background(), support)
+	var statusErr *ai_provider.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 401 {
+		t.Fatalf("expected the plugin to reject the token, got %v", err)
+	}
+	if wf_operations.IsRetryable(err) {
+		t.Error("expected a rejected token not to be retried")
+	}
+	if condition := meta.FindStatusCondition(support.Status.Conditions, v1alpha2.SupportConditionProviderReachable); condition == nil || condition.Status != metav1.ConditionFalse {
+		t.Errorf("expected ProviderReachable to be false, got %+v", condition)
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	server := standIn(t, []string{protocol.ProtocolV1}, func(ctx context.Context, req *protocol.AnalyzeRequest) (*v1alpha2.Result, error) {
+		if req.Support.Namespace == "team-a" {
+			return nil, protocol.BadRequest(errors.New("unsupported target"))
+		}
+		return nil, errors.New("backend unavailable")
+	})
+	executor, support := newTestExecutor(server.URL, testToken, time.Minute)
+
+	if _, err := executor.Process(context.Background(), support); err == nil || wf_operations.IsRetryable(err) {
+		t.Errorf("expected a bad request not to be retried, got %v", err)
+	}
+	support.Namespace = "team-b"
+	if _, err := executor.Process(context.Background(), support); err == nil || !wf_operations.IsRetryable(err) {
+		t.Errorf("expected a plugin failure to be retried, got %v", err)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	server := standIn(t, []string{protocol.ProtocolV1}, func(ctx context.Context, req *protocol.AnalyzeRequest) (*v1alpha2.Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	executor, support := newTestExecutor(server.URL, testToken, 50*time.Millisecond)
+
+	_, err := executor.Process(context.Background(), support)
+	var stageErr *wf_operations.StageError
+	if !errors.As(err, &stageErr) || !stageErr.TimedOut || stageErr.Stage != wf_operations.StagePlugin {
+		t.Fatalf("expected the plugin stage to time out, got %v", err)
+	}
+	if !wf_operations.IsRetryable(err) {
+		t.Error("expected a timeout to be retried")
+	}
+}
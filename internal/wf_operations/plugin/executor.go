@@ -0,0 +1,186 @@
+// Package plugin runs workflows in executor plugins, services speaking the protocol of
+// pkg/plugin, for workflows referencing an AuthProvider of type plugin.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations"
+	"github.com/argoproj-labs/argo-support/internal/wf_operations/genai"
+	protocol "github.com/argoproj-labs/argo-support/pkg/plugin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PluginExecutor collects the context of a Support and hands it to an executor plugin
+type PluginExecutor struct {
+	client ai_provider.HttpClient
+	params wf_operations.ExecutorParams
+	// collect gathers the context handed to the plugin
+	collect func(ctx context.Context, params wf_operations.ExecutorParams) (string, error)
+}
+
+var (
+	_ wf_operations.Executor = &PluginExecutor{}
+)
+
+func init() {
+	wf_operations.RegisterProviderExecutor(v1alpha2.AuthProviderTypePlugin, NewPluginExecutor)
+}
+
+// NewPluginExecutor creates a PluginExecutor for the first referenced plugin AuthProvider serving the workflow
+func NewPluginExecutor(ctx context.Context, params wf_operations.ExecutorParams) (wf_operations.Executor, error) {
+	for _, authProvider := range params.AuthProviders {
+		if !authProvider.Spec.ServesWorkflow(params.Workflow.Name) {
+			continue
+		}
+		client, err := params.ProviderClients.Client(ctx, params.Client, &authProvider)
+		if err != nil {
+			return nil, wf_operations.ProviderClientError(err)
+		}
+		return &PluginExecutor{client: *client, params: params, collect: genai.CollectContext}, nil
+	}
+	return nil, wf_operations.Permanent(&wf_operations.UnknownWorkflowError{Name: params.Workflow.Name})
+}
+
+func (p *PluginExecutor) Process(ctx context.Context, obj metav1.Object) (*v1alpha2.Result, error) {
+	logger := log.FromContext(ctx)
+
+	support, ok := obj.(*v1alpha2.Support)
+	if !ok {
+		return nil, fmt.Errorf("type assertion to *v1alpha2.Support failed")
+	}
+
+	params := p.params
+	params.Support = support
+	collected, err := p.collect(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.AnalyzeRequest{
+		Workflow: *p.params.Workflow,
+		Support:  *support.DeepCopy(),
+		Target:   support.Spec.Target,
+		Context:  collected,
+		Config:   p.params.Config,
+		Inputs:   p.params.Inputs,
+	}
+	// the plugin gets the spec it runs for, the status only describes the controller's progress
+	request.Support.Status = v1alpha2.SupportStatus{}
+
+	var result *v1alpha2.Result
+	err = wf_operations.RunStage(ctx, p.params.StageTimeouts, wf_operations.StagePlugin, func(ctx context.Context) error {
+		manifest, err := p.manifest(ctx)
+		if err != nil {
+			return err
+		}
+		version, ok := protocol.NegotiateVersion(protocol.SupportedVersions, manifest.ProtocolVersions)
+		if !ok {
+			return wf_operations.Permanent(fmt.Errorf("plugin %q speaks protocol versions %v, the controller speaks %v",
+				manifest.Name, manifest.ProtocolVersions, protocol.SupportedVersions))
+		}
+		logger.Info("running workflow in plugin", "plugin", manifest.Name, "protocolVersion", version, "context length", len(collected))
+
+		request.APIVersion = version
+		var response protocol.AnalyzeResponse
+		if err := p.do(ctx, http.MethodPost, "/"+version+protocol.AnalyzePath, &request, &response); err != nil {
+			return err
+		}
+		if response.APIVersion != version {
+			return wf_operations.Permanent(fmt.Errorf("plugin answered with protocol version %q to a %q request", response.APIVersion, version))
+		}
+		result = response.Result
+		return nil
+	})
+	if err != nil {
+		support.SetCondition(v1alpha2.SupportConditionProviderReachable, metav1.ConditionFalse, v1alpha2.SupportReasonRequestFailed, err.Error())
+		return nil, fmt.Errorf("failed to run workflow in plugin: %w", err)
+	}
+	support.SetCondition(v1alpha2.SupportConditionProviderReachable, metav1.ConditionTrue, v1alpha2.SupportReasonReachable,
+		fmt.Sprintf("plugin at %s answered", p.client.BaseURL))
+
+	if result == nil {
+		err := fmt.Errorf("plugin returned no result")
+		support.SetCondition(v1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionFalse, v1alpha2.SupportReasonInvalidResponse, err.Error())
+		return nil, err
+	}
+
+	// the name and phase are the controller's, the name of an archived result has to be unique
+	// per run and attempt and a valid object name
+	now := metav1.Now()
+	result.Name = wf_operations.ResultName(support, p.params.Workflow.Name, now.Time)
+	result.Phase = ""
+	if result.FinishedAt == nil {
+		result.FinishedAt = &now
+	}
+	if result.Message == "" {
+		result.Message = fmt.Sprintf("workflow %s completed in plugin", p.params.Workflow.Name)
+	}
+	support.SetCondition(v1alpha2.SupportConditionAnalysisSucceeded, metav1.ConditionTrue, v1alpha2.SupportReasonSucceeded, result.Message)
+	return result, nil
+}
+
+// manifest reads the manifest of the plugin
+func (p *PluginExecutor) manifest(ctx context.Context) (*protocol.Manifest, error) {
+	var manifest protocol.Manifest
+	if err := p.do(ctx, http.MethodGet, protocol.ManifestPath, nil, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// do sends body to the path below the base URL of the plugin and decodes the answer into out
+func (p *PluginExecutor) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.client.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	authorizationHeader, err := p.client.AuthorizationHeader(ctx)
+	if err != nil {
+		return err
+	}
+	p.client.SetAuthorization(req, authorizationHeader)
+	for key, value := range p.client.Headers {
+		req.Header.Add(key, value)
+	}
+
+	resp, err := p.client.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		statusErr := &ai_provider.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		var errorResponse protocol.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&errorResponse) == nil && errorResponse.Error != "" {
+			return fmt.Errorf("%s: %w", errorResponse.Error, statusErr)
+		}
+		return statusErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from plugin: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package wf_operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+	"github.com/argoproj-labs/argo-support/internal/utils"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Dependencies are the clients and settings of the controller shared by all executors
+type Dependencies struct {
+	Client        client.Client
+	DynamicClient dynamic.DynamicClient
+	KubeClient    kubernetes.Interface
+	StageTimeouts StageTimeouts
+	// ProviderClients shares the clients of AuthProviders between attempts, a client is built for
+	// every attempt when it is nil
+	ProviderClients *ai_provider.ClientPool
+}
+
+// ExecutorParams is what a factory builds the executor of a workflow from
+type ExecutorParams struct {
+	Dependencies
+	// Workflow is the workflow of the Support the executor runs
+	Workflow *v1alpha2.Workflow
+	// Support is the Support the workflow belongs to
+	Support *v1alpha2.Support
+	// AuthProviders are the AuthProviders referenced by the workflow
+	AuthProviders []v1alpha2.AuthProvider
+	// Config is the workflow ConfigMap layered over the controller defaults
+	Config map[string]string
+	// Inputs are the latest results of the workflows listed in dependsOn, by workflow name
+	Inputs map[string]v1alpha2.Result
+}
+
+// ExecutorFactory builds the executor of a workflow
+type ExecutorFactory func(ctx context.Context, params ExecutorParams) (Executor, error)
+
+var (
+	executorFactoriesMu       sync.RWMutex
+	executorFactories         = map[string]ExecutorFactory{}
+	providerExecutorFactories = map[v1alpha2.AuthProviderType]ExecutorFactory{}
+)
+
+// RegisterExecutor makes factory responsible for the workflows with the given name.
+// It is meant to be called from init functions of the packages adding a workflow.
+func RegisterExecutor(name string, factory ExecutorFactory) {
+	executorFactoriesMu.Lock()
+	defer executorFactoriesMu.Unlock()
+	executorFactories[name] = factory
+}
+
+// RegisterProviderExecutor makes factory run the workflows that have no executor registered under
+// their name but reference an AuthProvider of the given type serving them, such as a plugin.
+// It is meant to be called from init functions of the packages adding a provider type.
+func RegisterProviderExecutor(providerType v1alpha2.AuthProviderType, factory ExecutorFactory) {
+	executorFactoriesMu.Lock()
+	defer executorFactoriesMu.Unlock()
+	providerExecutorFactories[providerType] = factory
+}
+
+// RegisteredWorkflows returns the sorted names of the registered workflows
+func RegisteredWorkflows() []string {
+	executorFactoriesMu.RLock()
+	defer executorFactoriesMu.RUnlock()
+	names := make([]string, 0, len(executorFactories))
+	for name := range executorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRegistered reports whether an executor is registered for the named workflow
+func IsRegistered(name string) bool {
+	executorFactoriesMu.RLock()
+	defer executorFactoriesMu.RUnlock()
+	_, ok := executorFactories[name]
+	return ok
+}
+
+// IsRunnable reports whether the named workflow has an executor, either registered under its name
+// or provided by one of the AuthProviders it references
+func IsRunnable(name string, authProviders []v1alpha2.AuthProvider) bool {
+	if IsRegistered(name) {
+		return true
+	}
+	_, ok := providerExecutor(name, authProviders)
+	return ok
+}
+
+// providerExecutor returns the factory of the first AuthProvider serving the named workflow
+func providerExecutor(name string, authProviders []v1alpha2.AuthProvider) (ExecutorFactory, bool) {
+	executorFactoriesMu.RLock()
+	defer executorFactoriesMu.RUnlock()
+	for _, authProvider := range authProviders {
+		factory, ok := providerExecutorFactories[authProvider.Spec.Type]
+		if ok && authProvider.Spec.ServesWorkflow(name) {
+			return factory, true
+		}
+	}
+	return nil, false
+}
+
+// UnknownWorkflowError is returned for workflows no executor is registered for
+type UnknownWorkflowError struct {
+	Name string
+}
+
+func (e *UnknownWorkflowError) Error() string {
+	return fmt.Sprintf("no executor is registered for workflow %q, known workflows are %v", e.Name, RegisteredWorkflows())
+}
+
+// NewExecutor resolves the AuthProviders of the workflow and builds its executor with the factory
+// registered under its name, or the one of a referenced AuthProvider serving it. The AuthProviders
+// of params are filled in from the references of the workflow.
+func NewExecutor(ctx context.Context, params ExecutorParams) (Executor, error) {
+	wf := params.Workflow
+	if !IsRegistered(wf.Name) && len(wf.AuthProviderRefs) == 0 {
+		return nil, Permanent(&UnknownWorkflowError{Name: wf.Name})
+	}
+	authProviders, err := utils.GetAuthProviders(ctx, params.Client, &wf.AuthProviderRefs, params.Support.Namespace)
+	var forbidden *utils.ForbiddenAuthProvidersError
+	if errors.As(err, &forbidden) {
+		return nil, Permanent(err)
+	} else if err != nil {
+		return nil, err
+	}
+	params.AuthProviders = *authProviders
+
+	executorFactoriesMu.RLock()
+	factory, ok := executorFactories[wf.Name]
+	executorFactoriesMu.RUnlock()
+	if !ok {
+		if factory, ok = providerExecutor(wf.Name, params.AuthProviders); !ok {
+			return nil, Permanent(&UnknownWorkflowError{Name: wf.Name})
+		}
+	}
+	return factory(ctx, params)
+}
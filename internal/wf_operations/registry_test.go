@@ -0,0 +1,67 @@
+package wf_operations
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeExecutor struct {
+	params ExecutorParams
+}
+
+func (e *fakeExecutor) Process(ctx context.Context, obj metav1.Object) (*v1alpha2.Result, error) {
+	return &v1alpha2.Result{Name: e.params.Workflow.Name}, nil
+}
+
+func TestNewExecutor(t *testing.T) {
+	RegisterExecutor("in-house", func(ctx context.Context, params ExecutorParams) (Executor, error) {
+		return &fakeExecutor{params: params}, nil
+	})
+	if !IsRegistered("in-house") || !slices.Contains(RegisteredWorkflows(), "in-house") {
+		t.Fatal("expected the workflow to be registered")
+	}
+
+	scheme := runtime.NewScheme()
+	if err := v1alpha2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha2.AuthProvider{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "llm"},
+	}).Build()
+	support := &v1alpha2.Support{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "support"}}
+	wf := &v1alpha2.Workflow{Name: "in-house", AuthProviderRefs: []v1alpha2.NamespacedObjectReference{{Name: "llm"}}}
+
+	executor, err := NewExecutor(context.Background(), ExecutorParams{
+		Dependencies: Dependencies{Client: k8sClient},
+		Workflow:     wf,
+		Support:      support,
+		Config:       map[string]string{"key": "value"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	params := executor.(*fakeExecutor).params
+	if len(params.AuthProviders) != 1 || params.AuthProviders[0].Name != "llm" || params.Config["key"] != "value" || params.Support != support {
+		t.Errorf("unexpected params %+v", params)
+	}
+
+	_, err = NewExecutor(context.Background(), ExecutorParams{
+		Dependencies: Dependencies{Client: k8sClient},
+		Workflow:     &v1alpha2.Workflow{Name: "unknown"},
+		Support:      support,
+	})
+	var unknown *UnknownWorkflowError
+	if !errors.As(err, &unknown) || unknown.Name != "unknown" {
+		t.Fatalf("expected an unknown workflow error, got %v", err)
+	}
+	if IsRetryable(err) {
+		t.Error("expected an unknown workflow not to be retried")
+	}
+}
@@ -0,0 +1,29 @@
+package wf_operations
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+)
+
+// ResultName names the result an attempt of the workflow finished at the given time. The name
+// carries the run and the attempt the status of the Support records for the workflow, so results
+// of different runs and attempts do not share a name even when they finish within the same second.
+func ResultName(support *v1alpha2.Support, workflow string, finishedAt time.Time) string {
+	name := fmt.Sprintf("%s-%d", workflow, finishedAt.Unix())
+	for _, status := range support.Status.Workflows {
+		if status.Name == workflow {
+			return fmt.Sprintf("%s-%s-%d", name, runHash(status.RunID), status.Attempts)
+		}
+	}
+	return name
+}
+
+// runHash shortens a run ID, which may hold characters object names do not allow, to eight hex digits
+func runHash(runID string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(runID))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
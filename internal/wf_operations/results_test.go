@@ -0,0 +1,34 @@
+package wf_operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestResultName(t *testing.T) {
+	finishedAt := time.Unix(1700000000, 0)
+	support := &v1alpha2.Support{Status: v1alpha2.SupportStatus{Workflows: []v1alpha2.WorkflowStatus{
+		{Name: "gen-ai", RunID: "2024-05-01T10:00:00Z", Attempts: 1},
+	}}}
+
+	first := ResultName(support, "gen-ai", finishedAt)
+	if errs := validation.IsDNS1123Subdomain(first); len(errs) > 0 {
+		t.Errorf("expected %q to be a valid object name, got %v", first, errs)
+	}
+
+	support.Status.Workflows[0].Attempts = 2
+	retried := ResultName(support, "gen-ai", finishedAt)
+	support.Status.Workflows[0].RunID = "2024-05-01T10:00:01Z"
+	support.Status.Workflows[0].Attempts = 1
+	rerun := ResultName(support, "gen-ai", finishedAt)
+	if first == retried || first == rerun || retried == rerun {
+		t.Errorf("expected results of different runs and attempts to have different names, got %q, %q and %q", first, retried, rerun)
+	}
+
+	if name := ResultName(support, "triage", finishedAt); name != "triage-1700000000" {
+		t.Errorf("expected a workflow without status to be named by the time only, got %q", name)
+	}
+}
@@ -0,0 +1,102 @@
+package wf_operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Stage names a step of a workflow attempt that runs under its own deadline
+type Stage string
+
+// Possible Stage values
+const (
+	// StageArgoCD fetches the Argo CD Application of the target
+	StageArgoCD Stage = "argocd"
+	// StageCollection collects the cluster context of the target
+	StageCollection Stage = "collection"
+	// StageIdentity exchanges the credentials for an authorization header
+	StageIdentity Stage = "identity"
+	// StageLLM sends the context to the LLM provider and waits for the analysis
+	StageLLM Stage = "llm"
+	// StagePlugin hands the context to an executor plugin and waits for its result
+	StagePlugin Stage = "plugin"
+)
+
+// StageTimeouts are the deadlines of the stages of an attempt, a zero value leaves a stage
+// bounded by its parent context only
+type StageTimeouts struct {
+	ArgoCD     time.Duration
+	Collection time.Duration
+	Identity   time.Duration
+	LLM        time.Duration
+	Plugin     time.Duration
+}
+
+// DefaultStageTimeouts are used by executors without configured deadlines
+var DefaultStageTimeouts = StageTimeouts{
+	ArgoCD:     30 * time.Second,
+	Collection: 2 * time.Minute,
+	Identity:   30 * time.Second,
+	LLM:        5 * time.Minute,
+	Plugin:     5 * time.Minute,
+}
+
+// For returns the deadline of the stage
+func (t StageTimeouts) For(stage Stage) time.Duration {
+	switch stage {
+	case StageArgoCD:
+		return t.ArgoCD
+	case StageCollection:
+		return t.Collection
+	case StageIdentity:
+		return t.Identity
+	case StageLLM:
+		return t.LLM
+	case StagePlugin:
+		return t.Plugin
+	default:
+		return 0
+	}
+}
+
+// StageError reports the stage of an attempt that failed and whether it ran out of time
+type StageError struct {
+	Stage    Stage
+	Timeout  time.Duration
+	TimedOut bool
+	Err      error
+}
+
+func (e *StageError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("%s stage timed out after %s: %v", e.Stage, e.Timeout, e.Err)
+	}
+	return fmt.Sprintf("%s stage failed: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// RunStage runs fn under the deadline of the stage and returns its failure as a StageError. A stage
+// that ran out of time or was cancelled fails even when fn tolerated the errors it ran into.
+func RunStage(ctx context.Context, timeouts StageTimeouts, stage Stage, fn func(ctx context.Context) error) error {
+	timeout := timeouts.For(stage)
+	stageCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	err := fn(stageCtx)
+	timedOut := ctx.Err() == nil && errors.Is(stageCtx.Err(), context.DeadlineExceeded)
+	if err == nil {
+		err = stageCtx.Err()
+	}
+	if err == nil {
+		return nil
+	}
+	return &StageError{Stage: stage, Timeout: timeout, TimedOut: timedOut, Err: err}
+}
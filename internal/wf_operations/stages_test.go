@@ -0,0 +1,75 @@
+package wf_operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/argoproj-labs/argo-support/internal/services/ai_provider"
+)
+
+func TestRunStageTimeout(t *testing.T) {
+	timeouts := StageTimeouts{LLM: 10 * time.Millisecond}
+	err := RunStage(context.Background(), timeouts, StageLLM, func(ctx context.Context) error {
+		<-ctx.Done()
+		return fmt.Errorf("failed to post request: %v", ctx.Err())
+	})
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("expected a StageError, got %v", err)
+	}
+	if stageErr.Stage != StageLLM || !stageErr.TimedOut {
+		t.Errorf("expected the llm stage to time out, got %+v", stageErr)
+	}
+	if !strings.Contains(err.Error(), "llm stage timed out after 10ms") {
+		t.Errorf("expected the message to name the stage, got %q", err.Error())
+	}
+	if !IsRetryable(err) {
+		t.Error("expected a timeout to be retryable")
+	}
+}
+
+func TestRunStageTimeoutOfTolerantStage(t *testing.T) {
+	timeouts := StageTimeouts{Collection: 10 * time.Millisecond}
+	err := RunStage(context.Background(), timeouts, StageCollection, func(ctx context.Context) error {
+		// the collection logs what it cannot fetch and carries on
+		<-ctx.Done()
+		return nil
+	})
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || !stageErr.TimedOut || stageErr.Stage != StageCollection {
+		t.Fatalf("expected the collection stage to time out, got %v", err)
+	}
+}
+
+func TestRunStageKeepsErrorClass(t *testing.T) {
+	err := RunStage(context.Background(), DefaultStageTimeouts, StageLLM, func(ctx context.Context) error {
+		return &ai_provider.StatusError{StatusCode: 400, Status: "400 Bad Request"}
+	})
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.TimedOut {
+		t.Fatalf("expected a failed stage, got %v", err)
+	}
+	if IsRetryable(err) {
+		t.Error("expected a client error to stay permanent")
+	}
+}
+
+func TestRunStageCancelledParent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RunStage(ctx, DefaultStageTimeouts, StageArgoCD, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.TimedOut {
+		t.Fatalf("expected a cancelled stage not to count as timed out, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package workerpool runs long tasks, such as workflow analyses, outside of the reconcile loop on
+// a bounded number of workers shared fairly between namespaces.
+package workerpool
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Task is a unit of work queued in the namespace it belongs to
+type Task struct {
+	// Key identifies the task, a key is queued or running at most once at a time
+	Key string
+	// Namespace is the namespace the task is accounted to for fairness
+	Namespace string
+	// Run does the work, ctx is cancelled when the task is cancelled or the manager stops
+	Run func(ctx context.Context)
+	// OnDone, when set, is called after Run returned and the key is no longer active, ctx is
+	// cancelled when the manager stops
+	OnDone func(ctx context.Context)
+}
+
+// Pool runs submitted tasks on a fixed number of workers. Namespaces with queued tasks take turns,
+// so a namespace with many tasks does not hold back the others.
+type Pool struct {
+	workers         int
+	maxPerNamespace int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  map[string][]Task
+	order   []string
+	running map[string]int
+	active  map[string]bool
+	cancels map[string]context.CancelFunc
+	stopped bool
+}
+
+var _ manager.Runnable = &Pool{}
+var _ manager.LeaderElectionRunnable = &Pool{}
+
+// New creates a pool running workers tasks at a time and, when maxPerNamespace is positive, at
+// most maxPerNamespace tasks of the same namespace at a time
+func New(workers, maxPerNamespace int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{
+		workers:         workers,
+		maxPerNamespace: maxPerNamespace,
+		queues:          map[string][]Task{},
+		running:         map[string]int{},
+		active:          map[string]bool{},
+		cancels:         map[string]context.CancelFunc{},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Submit queues the task and reports whether it was accepted. A task whose key is already queued
+// or running is dropped, as is every task submitted after the pool stopped.
+func (p *Pool) Submit(task Task) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || p.active[task.Key] {
+		return false
+	}
+	p.active[task.Key] = true
+	if len(p.queues[task.Namespace]) == 0 {
+		p.order = append(p.order, task.Namespace)
+	}
+	p.queues[task.Namespace] = append(p.queues[task.Namespace], task)
+	p.cond.Signal()
+	return true
+}
+
+// Active reports whether a task with the key is queued or running
+func (p *Pool) Active(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active[key]
+}
+
+// Cancel drops the queued task with the key or cancels the context of the running one, and reports
+// whether a task was found
+func (p *Pool) Cancel(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active[key] {
+		return false
+	}
+	if cancel, ok := p.cancels[key]; ok {
+		cancel()
+		return true
+	}
+	for namespace, queue := range p.queues {
+		for i, task := range queue {
+			if task.Key != key {
+				continue
+			}
+			p.queues[namespace] = append(queue[:i:i], queue[i+1:]...)
+			if len(p.queues[namespace]) == 0 {
+				delete(p.queues, namespace)
+				p.order = slices.DeleteFunc(p.order, func(n string) bool { return n == namespace })
+			}
+			delete(p.active, key)
+			return true
+		}
+	}
+	return false
+}
+
+// Start runs the workers until ctx is done and then waits for the running tasks to return. Queued
+// tasks that did not start are dropped.
+func (p *Pool) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("workerpool")
+	logger.Info("starting workers", "workers", p.workers, "maxPerNamespace", p.maxPerNamespace)
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	p.mu.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	wg.Wait()
+	logger.Info("stopped workers")
+	return nil
+}
+
+// NeedLeaderElection makes only the leader run analyses, as only the leader reconciles Supports
+func (p *Pool) NeedLeaderElection() bool {
+	return true
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		task, taskCtx, ok := p.next(ctx)
+		if !ok {
+			return
+		}
+		task.Run(taskCtx)
+		p.done(task)
+		if task.OnDone != nil {
+			task.OnDone(ctx)
+		}
+	}
+}
+
+// next blocks until a task may start or the pool stops and returns the task with its context
+func (p *Pool) next(ctx context.Context) (Task, context.Context, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if p.stopped {
+			return Task{}, nil, false
+		}
+		for i, namespace := range p.order {
+			if p.maxPerNamespace > 0 && p.running[namespace] >= p.maxPerNamespace {
+				continue
+			}
+			queue := p.queues[namespace]
+			task := queue[0]
+			p.order = append(p.order[:i:i], p.order[i+1:]...)
+			if len(queue) > 1 {
+				p.queues[namespace] = queue[1:]
+				// the namespace goes to the back of the line for its next task
+				p.order = append(p.order, namespace)
+			} else {
+				delete(p.queues, namespace)
+			}
+			p.running[namespace]++
+			taskCtx, cancel := context.WithCancel(ctx)
+			p.cancels[task.Key] = cancel
+			return task, taskCtx, true
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *Pool) done(task Task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancels[task.Key]()
+	delete(p.cancels, task.Key)
+	delete(p.active, task.Key)
+	p.running[task.Namespace]--
+	if p.running[task.Namespace] == 0 {
+		delete(p.running, task.Namespace)
+	}
+	// a namespace held back by maxPerNamespace may go again
+	p.cond.Broadcast()
+}
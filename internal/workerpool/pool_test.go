@@ -0,0 +1,146 @@
+package workerpool
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolTakesTurnsBetweenNamespaces(t *testing.T) {
+	pool := New(1, 0)
+
+	var mu sync.Mutex
+	var ran []string
+	var wg sync.WaitGroup
+	submit := func(namespace, name string) {
+		wg.Add(1)
+		accepted := pool.Submit(Task{Key: namespace + "/" + name, Namespace: namespace, Run: func(ctx context.Context) {
+			defer wg.Done()
+			mu.Lock()
+			ran = append(ran, namespace+"/"+name)
+			mu.Unlock()
+		}})
+		if !accepted {
+			t.Fatalf("expected %s/%s to be accepted", namespace, name)
+		}
+	}
+	submit("team-a", "1")
+	submit("team-a", "2")
+	submit("team-a", "3")
+	submit("team-b", "1")
+
+	if pool.Submit(Task{Key: "team-a/1", Namespace: "team-a", Run: func(ctx context.Context) {}}) {
+		t.Error("expected a queued key to be rejected")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = pool.Start(ctx) }()
+	wg.Wait()
+
+	want := []string{"team-a/1", "team-b/1", "team-a/2", "team-a/3"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("expected order %v, got %v", want, ran)
+	}
+}
+
+func TestPoolLimitsTasksPerNamespace(t *testing.T) {
+	pool := New(3, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = pool.Start(ctx) }()
+
+	release := make(chan struct{})
+	started := make(chan string, 3)
+	for _, key := range []string{"team-a/1", "team-a/2", "team-b/1"} {
+		key := key
+		namespace := key[:len("team-a")]
+		pool.Submit(Task{Key: key, Namespace: namespace, Run: func(ctx context.Context) {
+			started <- key
+			<-release
+		}})
+	}
+
+	got := map[string]bool{<-started: true, <-started: true}
+	if !got["team-a/1"] || !got["team-b/1"] {
+		t.Errorf("expected the first task of each namespace to start, got %v", got)
+	}
+	select {
+	case key := <-started:
+		t.Errorf("expected %s to wait for a free slot in its namespace", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !pool.Active("team-a/2") {
+		t.Error("expected the waiting task to be active")
+	}
+
+	close(release)
+	if key := <-started; key != "team-a/2" {
+		t.Errorf("expected team-a/2 to start, got %s", key)
+	}
+}
+
+func TestPoolCancel(t *testing.T) {
+	pool := New(1, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = pool.Start(ctx) }()
+
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	pool.Submit(Task{Key: "team-a/running", Namespace: "team-a", Run: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+	}})
+	ran := false
+	pool.Submit(Task{Key: "team-a/queued", Namespace: "team-a", Run: func(ctx context.Context) { ran = true }})
+	<-started
+
+	if !pool.Cancel("team-a/queued") || pool.Active("team-a/queued") {
+		t.Error("expected the queued task to be dropped")
+	}
+	if !pool.Cancel("team-a/running") {
+		t.Error("expected the running task to be found")
+	}
+	if err := <-stopped; err != context.Canceled {
+		t.Errorf("expected the running task to be cancelled, got %v", err)
+	}
+	if pool.Cancel("team-a/unknown") {
+		t.Error("expected an unknown key not to be found")
+	}
+	if ran {
+		t.Error("expected the dropped task not to run")
+	}
+}
+
+func TestPoolCallsOnDoneOnceTheKeyIsFree(t *testing.T) {
+	pool := New(1, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = pool.Start(ctx) }()
+
+	activeOnDone := make(chan bool, 1)
+	pool.Submit(Task{
+		Key:       "team-a/1",
+		Namespace: "team-a",
+		Run:       func(ctx context.Context) {},
+		OnDone: func(ctx context.Context) {
+			activeOnDone <- pool.Active("team-a/1")
+		},
+	})
+
+	select {
+	case active := <-activeOnDone:
+		if active {
+			t.Error("expected the key to be inactive when OnDone runs")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnDone to be called")
+	}
+	if !pool.Submit(Task{Key: "team-a/1", Namespace: "team-a", Run: func(ctx context.Context) {}}) {
+		t.Error("expected the key to be accepted again after the task finished")
+	}
+}
@@ -39,11 +39,17 @@
         workflows.configMapRef.name =  "genai-cm"
         workflows.autProviderRef = {}
         workflows.autProviderRef[1] = {}
-        workflows.autProviderRef[1].name = "genai-authprovider"
+        workflows.autProviderRef[1].name = "genai-auth-provider"
         workflows.autProviderRef[2] = {}
         workflows.autProviderRef[2].name = "argocd-auth-provider"
         spec.workflows = {}
         spec.workflows[1] = workflows
+        spec.target = {}
+        spec.target.group = "argoproj.io"
+        spec.target.version = "v1alpha1"
+        spec.target.kind = "Rollout"
+        spec.target.name = obj.metadata.name
+        spec.target.application = obj.metadata.labels["app.kubernetes.io/instance"]
         genaiObj.spec = spec
         impactedResource = {}
         impactedResource.operation = "create"
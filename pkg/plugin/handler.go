@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+)
+
+// AnalyzeFunc runs a workflow for the request. Errors wrapped with BadRequest are answered with a
+// 400 status, all others with a 500 status.
+type AnalyzeFunc func(ctx context.Context, req *AnalyzeRequest) (*v1alpha2.Result, error)
+
+// badRequestError marks an error caused by the request
+type badRequestError struct {
+	err error
+}
+
+func (e *badRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badRequestError) Unwrap() error {
+	return e.err
+}
+
+// BadRequest marks err as caused by the request, the controller does not retry it
+func BadRequest(err error) error {
+	return &badRequestError{err: err}
+}
+
+// Handler serves the plugin protocol
+type Handler struct {
+	// Manifest is served on the manifest path, its protocol versions select the analyze endpoints
+	Manifest Manifest
+	// Token is the bearer token requests must carry, requests are not authenticated when empty
+	Token string
+	// Analyze runs the workflows
+	Analyze AnalyzeFunc
+}
+
+var _ http.Handler = &Handler{}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+h.Token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "missing or invalid bearer token"})
+		return
+	}
+
+	if r.URL.Path == ManifestPath {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "manifest only supports GET"})
+			return
+		}
+		writeJSON(w, http.StatusOK, h.Manifest)
+		return
+	}
+
+	for _, version := range h.Manifest.ProtocolVersions {
+		if r.URL.Path == "/"+version+AnalyzePath {
+			h.analyze(w, r, version)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("no endpoint at %s", r.URL.Path)})
+}
+
+func (h *Handler) analyze(w http.ResponseWriter, r *http.Request, version string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "analyze only supports POST"})
+		return
+	}
+	var req AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if req.APIVersion != version {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("request version %q does not match endpoint version %q", req.APIVersion, version)})
+		return
+	}
+	if len(h.Manifest.Workflows) > 0 && !slices.Contains(h.Manifest.Workflows, req.Workflow.Name) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("workflow %q is not run by this plugin", req.Workflow.Name)})
+		return
+	}
+
+	result, err := h.Analyze(r.Context(), &req)
+	var badRequest *badRequestError
+	switch {
+	case errors.As(err, &badRequest):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, AnalyzeResponse{APIVersion: version, Result: result})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plugin defines the protocol between the controller and executor plugins, services
+// running workflows out of process, and a handler plugins can serve it with.
+//
+// The controller first reads the manifest of the plugin from GET <baseUrl>/manifest and picks the
+// newest protocol version both sides speak. It then posts an AnalyzeRequest to
+// POST <baseUrl>/<version>/analyze and expects an AnalyzeResponse. Requests carry the secret of
+// the AuthProvider as a bearer token when it has one. Plugins answer errors with a 4xx status for
+// requests that must not be retried and a 5xx status for failures another attempt may fix.
+package plugin
+
+import (
+	"github.com/argoproj-labs/argo-support/api/v1alpha2"
+)
+
+// ProtocolV1 is the first version of the plugin protocol
+const ProtocolV1 = "v1"
+
+// SupportedVersions are the protocol versions the controller speaks, newest first
+var SupportedVersions = []string{ProtocolV1}
+
+const (
+	// ManifestPath is the path of the manifest below the base URL of a plugin
+	ManifestPath = "/manifest"
+	// AnalyzePath is the path of the analyze endpoint below the version segment
+	AnalyzePath = "/analyze"
+)
+
+// Manifest describes a plugin
+type Manifest struct {
+	// Name of the plugin
+	Name string `json:"name"`
+	// ProtocolVersions are the protocol versions the plugin speaks
+	ProtocolVersions []string `json:"protocolVersions"`
+	// Workflows are the names of the workflows the plugin runs
+	Workflows []string `json:"workflows,omitempty"`
+}
+
+// AnalyzeRequest asks the plugin to run a workflow for a Support
+type AnalyzeRequest struct {
+	// APIVersion is the protocol version of the request
+	APIVersion string `json:"apiVersion"`
+	// Workflow is the workflow of the Support to run
+	Workflow v1alpha2.Workflow `json:"workflow"`
+	// Support is the Support the workflow belongs to, without its status
+	Support v1alpha2.Support `json:"support"`
+	// Target is the object the Support analyzes, if it names one
+	Target *v1alpha2.TargetRef `json:"target,omitempty"`
+	// Context is the context the controller collected for the target
+	Context string `json:"context"`
+	// Config is the workflow ConfigMap layered over the controller defaults
+	Config map[string]string `json:"config,omitempty"`
+	// Inputs are the latest results of the workflows the workflow depends on, by workflow name
+	Inputs map[string]v1alpha2.Result `json:"inputs,omitempty"`
+}
+
+// AnalyzeResponse carries the result of the workflow
+type AnalyzeResponse struct {
+	// APIVersion is the protocol version of the response, it matches the one of the request
+	APIVersion string `json:"apiVersion"`
+	// Result of the workflow
+	Result *v1alpha2.Result `json:"result"`
+}
+
+// ErrorResponse is the body of responses with an error status
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// NegotiateVersion returns the newest of our versions the plugin speaks
+func NegotiateVersion(ours []string, theirs []string) (string, bool) {
+	for _, version := range ours {
+		for _, other := range theirs {
+			if version == other {
+				return version, true
+			}
+		}
+	}
+	return "", false
+}